@@ -1,6 +1,7 @@
 package moibit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -34,12 +35,18 @@ type responseAppDetails struct {
 
 // AppDetails returns the details of the application the client is configured for as a AppDescriptor object
 func (client *Client) AppDetails() (AppDescriptor, error) {
+	return client.AppDetailsContext(context.Background())
+}
+
+// AppDetailsContext returns the details of the application the client is configured for.
+// It behaves like AppDetails but carries the given context on the outgoing request.
+func (client *Client) AppDetailsContext(ctx context.Context) (AppDescriptor, error) {
 	if client.appID == "" {
 		return AppDescriptor{}, fmt.Errorf("request failed: no appID set for client")
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("GET", client.serviceURL("/appdetails"), nil)
+	requestHTTP, err := http.NewRequestWithContext(ctx, "GET", client.serviceURL("/appdetails"), nil)
 	if err != nil {
 		return AppDescriptor{}, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -48,21 +55,23 @@ func (client *Client) AppDetails() (AppDescriptor, error) {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return AppDescriptor{}, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseAppDetails
 	response := new(responseAppDetails)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return AppDescriptor{}, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return AppDescriptor{}, newDecodeError("/appdetails", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return AppDescriptor{}, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return AppDescriptor{}, newAPIError("/appdetails", responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file descriptors from the response
@@ -127,8 +136,14 @@ type responseDevDetails struct {
 
 // DevDetails returns the details of developer user the client is configured for as a DevDescriptor object
 func (client *Client) DevDetails() (DevDescriptor, error) {
+	return client.DevDetailsContext(context.Background())
+}
+
+// DevDetailsContext returns the details of developer user the client is configured for.
+// It behaves like DevDetails but carries the given context on the outgoing request.
+func (client *Client) DevDetailsContext(ctx context.Context) (DevDescriptor, error) {
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("GET", client.serviceURL("/devstat"), nil)
+	requestHTTP, err := http.NewRequestWithContext(ctx, "GET", client.serviceURL("/devstat"), nil)
 	if err != nil {
 		return DevDescriptor{}, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -137,21 +152,23 @@ func (client *Client) DevDetails() (DevDescriptor, error) {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return DevDescriptor{}, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseAppDetails
 	response := new(responseDevDetails)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return DevDescriptor{}, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return DevDescriptor{}, newDecodeError("/devstat", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return DevDescriptor{}, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return DevDescriptor{}, newAPIError("/devstat", responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file descriptors from the response
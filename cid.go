@@ -0,0 +1,314 @@
+package moibit
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// CIDVersion represents an enumeration for the versions of content identifiers
+// computed by a Hasher, matching the hashes that MOIBit assigns to files.
+type CIDVersion int
+
+const (
+	// CIDv0 is a base58 encoded multihash of a dag-pb DAG whose leaves are
+	// UnixFS file nodes (e.g. "Qm..."). This is the default for MOIBit files.
+	CIDv0 CIDVersion = iota
+
+	// CIDv1 is a base32 encoded CID of a dag-pb DAG whose leaves are raw
+	// blocks (e.g. "bafk..." or "bafy..."), as produced with raw leaves.
+	CIDv1
+)
+
+const (
+	// chunkSize is the size of the chunks the data is split into (the size-262144 chunker)
+	chunkSize = 256 << 10
+
+	// maxLinks is the maximum number of links of a node in the balanced DAG layout
+	maxLinks = 174
+
+	// codecDagPB and codecRaw are the multicodec codes of dag-pb and raw blocks
+	codecDagPB = 0x70
+	codecRaw   = 0x55
+)
+
+// CIDVersionOf returns the CIDVersion of the given hash.
+// Returns false if the hash is not a CID that can be computed by a Hasher, which is either a base58
+// encoded sha2-256 multihash (CIDv0) or a base32 encoded CIDv1 of a dag-pb or raw block with a sha2-256 multihash.
+func CIDVersionOf(hash string) (CIDVersion, bool) {
+	// CIDv0 is the 46 character base58 encoding of a sha2-256 multihash, which always starts with "Qm"
+	if len(hash) == 46 && strings.HasPrefix(hash, "Qm") {
+		for _, char := range hash {
+			if !strings.ContainsRune(base58Alphabet, char) {
+				return 0, false
+			}
+		}
+
+		return CIDv0, true
+	}
+
+	// CIDv1 is the lowercase base32 encoding of the binary CID prefixed with "b"
+	if !strings.HasPrefix(hash, "b") || strings.ToLower(hash) != hash {
+		return 0, false
+	}
+
+	cid, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(hash[1:]))
+	if err != nil {
+		return 0, false
+	}
+
+	// The binary CID is the version, the codec and the multihash
+	version, n := binary.Uvarint(cid)
+	if n <= 0 || version != 1 {
+		return 0, false
+	}
+
+	codec, m := binary.Uvarint(cid[n:])
+	if m <= 0 || (codec != codecDagPB && codec != codecRaw) {
+		return 0, false
+	}
+
+	multihash := cid[n+m:]
+	if len(multihash) != 2+sha256.Size || multihash[0] != 0x12 || multihash[1] != sha256.Size {
+		return 0, false
+	}
+
+	return CIDv1, true
+}
+
+// Hash computes the hash that MOIBit assigns to a file with the data read from the given reader.
+// The hash is the CID of the given version of the UnixFS DAG for the data, built with the default
+// parameters of IPFS: 256 KiB chunks and a balanced layout with up to 174 links per node.
+func Hash(r io.Reader, version CIDVersion) (string, error) {
+	hasher := NewHasher(version)
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+
+	return hasher.Sum(), nil
+}
+
+// HashBytes computes the hash that MOIBit assigns to a file with the given data.
+// See Hash for the parameters of the hash.
+func HashBytes(data []byte, version CIDVersion) string {
+	hasher := NewHasher(version)
+	_, _ = hasher.Write(data)
+	return hasher.Sum()
+}
+
+// Hasher is an io.Writer that computes the hash that MOIBit assigns to a file with the data written to it.
+// The data is chunked as it is written, so only the current chunk and the links to previous chunks are held in memory.
+type Hasher struct {
+	version CIDVersion
+	chunk   []byte
+	leaves  []dagLink
+}
+
+// dagLink is a link to a node of a UnixFS DAG
+type dagLink struct {
+	cid      []byte
+	tsize    uint64
+	filesize uint64
+}
+
+// NewHasher returns a new Hasher for the given CIDVersion
+func NewHasher(version CIDVersion) *Hasher {
+	return &Hasher{version: version, chunk: make([]byte, 0, chunkSize)}
+}
+
+// Write implements the io.Writer interface for Hasher
+func (hasher *Hasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := chunkSize - len(hasher.chunk)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		hasher.chunk = append(hasher.chunk, p[:n]...)
+		p = p[n:]
+
+		if len(hasher.chunk) == chunkSize {
+			hasher.flush()
+		}
+	}
+
+	return written, nil
+}
+
+// Size returns the number of bytes written to the Hasher
+func (hasher *Hasher) Size() int64 {
+	size := uint64(len(hasher.chunk))
+	for _, leaf := range hasher.leaves {
+		size += leaf.filesize
+	}
+
+	return int64(size)
+}
+
+// Sum returns the hash of the data written to the Hasher.
+// It does not change the state of the Hasher, so more data can be written after it.
+func (hasher *Hasher) Sum() string {
+	leaves := append([]dagLink(nil), hasher.leaves...)
+	if len(hasher.chunk) > 0 || len(leaves) == 0 {
+		leaves = append(leaves, hasher.leaf(hasher.chunk))
+	}
+
+	// Build the balanced layout bottom up until a single root remains
+	level := leaves
+	for len(level) > 1 {
+		parents := make([]dagLink, 0, (len(level)+maxLinks-1)/maxLinks)
+		for start := 0; start < len(level); start += maxLinks {
+			end := start + maxLinks
+			if end > len(level) {
+				end = len(level)
+			}
+
+			parents = append(parents, hasher.parent(level[start:end]))
+		}
+
+		level = parents
+	}
+
+	return hasher.encode(level[0].cid)
+}
+
+// flush hashes the current chunk as a leaf
+func (hasher *Hasher) flush() {
+	hasher.leaves = append(hasher.leaves, hasher.leaf(hasher.chunk))
+	hasher.chunk = hasher.chunk[:0]
+}
+
+// leaf returns the link to the leaf node for the given chunk.
+// Leaves are UnixFS file nodes for CIDv0 and raw blocks for CIDv1.
+func (hasher *Hasher) leaf(chunk []byte) dagLink {
+	if hasher.version == CIDv1 {
+		return dagLink{cid: hasher.cid(codecRaw, chunk), tsize: uint64(len(chunk)), filesize: uint64(len(chunk))}
+	}
+
+	// UnixFS Data: Type = File, Data = chunk, filesize = len(chunk).
+	// The Data field is omitted for the single empty leaf of an empty file.
+	unixfs := protoVarint(nil, 1, 2)
+	if len(chunk) > 0 {
+		unixfs = protoBytes(unixfs, 2, chunk)
+	}
+
+	unixfs = protoVarint(unixfs, 3, uint64(len(chunk)))
+
+	// PBNode: Data = UnixFS Data
+	node := protoBytes(nil, 1, unixfs)
+	return dagLink{cid: hasher.cid(codecDagPB, node), tsize: uint64(len(node)), filesize: uint64(len(chunk))}
+}
+
+// parent returns the link to the parent node for the given children
+func (hasher *Hasher) parent(children []dagLink) dagLink {
+	// UnixFS Data: Type = File, filesize = sum of children, blocksizes = filesize of each child
+	var filesize, tsize uint64
+	unixfs := protoVarint(nil, 1, 2)
+	for _, child := range children {
+		filesize += child.filesize
+	}
+
+	unixfs = protoVarint(unixfs, 3, filesize)
+	for _, child := range children {
+		unixfs = protoVarint(unixfs, 4, child.filesize)
+	}
+
+	// PBNode: Links (Hash, Name, Tsize) followed by Data
+	node := make([]byte, 0)
+	for _, child := range children {
+		link := protoBytes(nil, 1, child.cid)
+		link = protoBytes(link, 2, nil)
+		link = protoVarint(link, 3, child.tsize)
+
+		node = protoBytes(node, 2, link)
+		tsize += child.tsize
+	}
+
+	node = protoBytes(node, 1, unixfs)
+	return dagLink{cid: hasher.cid(codecDagPB, node), tsize: tsize + uint64(len(node)), filesize: filesize}
+}
+
+// cid returns the binary CID of the given block with the given codec.
+// A CIDv0 is the bare sha2-256 multihash of the block.
+func (hasher *Hasher) cid(codec uint64, block []byte) []byte {
+	digest := sha256.Sum256(block)
+	multihash := append([]byte{0x12, 0x20}, digest[:]...)
+
+	if hasher.version == CIDv0 {
+		return multihash
+	}
+
+	cid := binary.AppendUvarint(nil, 1)
+	cid = binary.AppendUvarint(cid, codec)
+	return append(cid, multihash...)
+}
+
+// encode returns the string form of the given binary CID
+func (hasher *Hasher) encode(cid []byte) string {
+	if hasher.version == CIDv0 {
+		return base58(cid)
+	}
+
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(cid))
+}
+
+// protoVarint appends a protobuf varint field with the given field number to the buffer
+func protoVarint(buffer []byte, field int, value uint64) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(field)<<3)
+	return binary.AppendUvarint(buffer, value)
+}
+
+// protoBytes appends a protobuf length-delimited field with the given field number to the buffer
+func protoBytes(buffer []byte, field int, value []byte) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(field)<<3|2)
+	buffer = binary.AppendUvarint(buffer, uint64(len(value)))
+	return append(buffer, value...)
+}
+
+// base58Alphabet is the bitcoin base58 alphabet used by multibase base58btc
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58 encodes the given bytes with the bitcoin base58 alphabet
+func base58(data []byte) string {
+	number := new(big.Int).SetBytes(data)
+	radix, remainder := big.NewInt(58), new(big.Int)
+
+	encoded := make([]byte, 0, len(data)*138/100+1)
+	for number.Sign() > 0 {
+		number.DivMod(number, radix, remainder)
+		encoded = append(encoded, base58Alphabet[remainder.Int64()])
+	}
+
+	// Leading zero bytes are encoded as the first character of the alphabet
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// Reverse the digits into big endian order
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+// HashFile computes the hash that MOIBit assigns to a file with the data of the local file with the given name.
+// See Hash for the parameters of the hash.
+func HashFile(name string, version CIDVersion) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+	return Hash(file, version)
+}
@@ -0,0 +1,179 @@
+package moibit_test
+
+import (
+	"bytes"
+	"encoding/base32"
+	"math/rand"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// vectorData returns the pseudo random data of the given size that the hash vectors are computed for.
+// It is the data generated by go-unixfs and go-unixfsnode for their stable CID tests.
+func vectorData(size int) []byte {
+	r := rand.New(rand.NewSource(0xdeadbeef))
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(r.Intn(255))
+	}
+
+	return data
+}
+
+// TestHashVectors checks the hashes against CIDs computed by IPFS for the same data.
+// The 10 MiB vectors are the stable CIDs tested by go-unixfs (CIDv0, the leaves are UnixFS file
+// nodes rather than raw nodes) and go-unixfsnode (CIDv1, raw leaves). The other CIDv1 vectors are
+// computed with the file builder of go-unixfsnode, the empty and "hello world" CIDv0 vectors are the
+// well known CIDs of kubo, and the remaining CIDv0 vectors are pinned from this implementation.
+func TestHashVectors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		v0   string
+		v1   string
+	}{
+		{"empty", nil, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH", "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"},
+		{"hello world", []byte("hello world\n"), "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", ""},
+		{"hello world raw", []byte("hello world"), "", "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"},
+		{"one chunk", vectorData(256 << 10), "QmZsarAkLHgRn8Zux5qbHgDHsTktougoFnC98hw1bza6ks", "bafkreidixxuzul3u4pxgtgplq7vejxfd2usyd7a7lxxxwj23a5fc3xmfhu"},
+		{"one chunk and a byte", vectorData(256<<10 + 1), "QmSwSgWgsbRHBZjkofhN5vgRmmnpBTiwHN7fWEC4PJ3vWV", "bafybeidgr5hool3wqripz54ezp7nkz6qendowgtfzuoh3xqmiqbt3frqna"},
+		{"10 MiB", vectorData(10 << 20), "QmZN1qquw84zhV4j6vT56tCcmFxaDaySL1ezTXFvMdNmrK", "bafybeieyxejezqto5xwcxtvh5tskowwxrn3hmbk3hcgredji3g7abtnfkq"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.v0 != "" {
+				if hash := moibit.HashBytes(test.data, moibit.CIDv0); hash != test.v0 {
+					t.Errorf("CIDv0 = %v, want %v", hash, test.v0)
+				}
+			}
+
+			if test.v1 != "" {
+				if hash := moibit.HashBytes(test.data, moibit.CIDv1); hash != test.v1 {
+					t.Errorf("CIDv1 = %v, want %v", hash, test.v1)
+				}
+			}
+		})
+	}
+}
+
+// TestHashDeepLayout checks the hashes of files with more chunks than the links of a node, which need a
+// second level of parents. The vectors are pinned from this implementation, which records the cumulative
+// size of the children as the Tsize of the links like go-unixfs and kubo (go-unixfsnode records the file size instead).
+func TestHashDeepLayout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("hashing 44 MiB in short mode")
+	}
+
+	data := vectorData(175<<18 + 1)
+
+	tests := []struct {
+		name string
+		data []byte
+		v0   string
+		v1   string
+	}{
+		{"175 chunks", data[:175<<18], "QmbiTgR6QqdsYJKyWejNmweA8xULxBUQpi4gYfE51ZT4Zw", "bafybeicvuvsrw4dfnodfnchqwshu6fg6wp6soiqizc24va46yjqettxtna"},
+		{"176 chunks", data, "QmPof4nGz9WsbGnuHWgZYE3owAgzRvM2ZL48z3H6DnHin3", "bafybeif6vuprd5j6hmlfuvqwl7ja7fccwidenrz3sgsmya7xht77l2xszq"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if hash := moibit.HashBytes(test.data, moibit.CIDv0); hash != test.v0 {
+				t.Errorf("CIDv0 = %v, want %v", hash, test.v0)
+			}
+
+			if hash := moibit.HashBytes(test.data, moibit.CIDv1); hash != test.v1 {
+				t.Errorf("CIDv1 = %v, want %v", hash, test.v1)
+			}
+		})
+	}
+}
+
+func TestHasher(t *testing.T) {
+	data := vectorData(3<<18 + 12345)
+
+	for _, version := range []moibit.CIDVersion{moibit.CIDv0, moibit.CIDv1} {
+		want := moibit.HashBytes(data, version)
+
+		// Write the data in pieces that do not align with the chunks
+		hasher := moibit.NewHasher(version)
+		for rest := data; len(rest) > 0; {
+			n := 100003
+			if n > len(rest) {
+				n = len(rest)
+			}
+
+			_, _ = hasher.Write(rest[:n])
+			rest = rest[n:]
+
+			// Summing does not change the state of the hasher
+			_ = hasher.Sum()
+		}
+
+		if hash := hasher.Sum(); hash != want {
+			t.Errorf("streamed hash = %v, want %v", hash, want)
+		}
+
+		if size := hasher.Size(); size != int64(len(data)) {
+			t.Errorf("Size() = %v, want %v", size, len(data))
+		}
+
+		hash, err := moibit.Hash(bytes.NewReader(data), version)
+		if err != nil || hash != want {
+			t.Errorf("Hash() = %v, %v, want %v", hash, err, want)
+		}
+	}
+}
+
+// encodeCIDv1 encodes a binary CIDv1 with the given version, codec and multihash as a hash string
+func encodeCIDv1(version, codec byte, multihash []byte) string {
+	cid := append([]byte{version, codec}, multihash...)
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(cid))
+}
+
+func TestCIDVersionOf(t *testing.T) {
+	sha256 := append([]byte{0x12, 0x20}, make([]byte, 32)...)
+
+	tests := []struct {
+		hash    string
+		version moibit.CIDVersion
+		ok      bool
+	}{
+		{"QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH", moibit.CIDv0, true},
+		{"bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", moibit.CIDv1, true},
+		{"bafybeieyxejezqto5xwcxtvh5tskowwxrn3hmbk3hcgredji3g7abtnfkq", moibit.CIDv1, true},
+		{encodeCIDv1(1, 0x70, sha256), moibit.CIDv1, true},
+
+		{"", 0, false},
+		{"b", 0, false},
+		{"bogus", 0, false},
+		{"bafy!!!", 0, false},
+		{"d41d8cd98f00b204e9800998ecf8427e", 0, false},
+		// Characters outside of the base58 alphabet
+		{"QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1Aw0l", 0, false},
+		// Too short for a sha2-256 multihash
+		{"QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1Aw", 0, false},
+		// Uppercase base32 has a different multibase prefix
+		{"BAFKREIHDWDCEFGH4DQKJV67UZCMW7OJEE6XEDZDETOJUZJEVTENXQUVYKU", 0, false},
+		{"bAFKREIHDWDCEFGH4DQKJV67UZCMW7OJEE6XEDZDETOJUZJEVTENXQUVYKU", 0, false},
+		// Truncated CID
+		{"bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvy", 0, false},
+		// Version, codec or multihash that cannot be computed by a Hasher
+		{encodeCIDv1(2, 0x70, sha256), 0, false},
+		{encodeCIDv1(1, 0x71, sha256), 0, false},
+		{encodeCIDv1(1, 0x55, append([]byte{0x13, 0x20}, make([]byte, 32)...)), 0, false},
+		{encodeCIDv1(1, 0x55, append([]byte{0x12, 0x20}, make([]byte, 31)...)), 0, false},
+		{encodeCIDv1(1, 0x55, append(sha256, 0)), 0, false},
+	}
+
+	for _, test := range tests {
+		version, ok := moibit.CIDVersionOf(test.hash)
+		if version != test.version || ok != test.ok {
+			t.Errorf("CIDVersionOf(%q) = %v, %v, want %v, %v", test.hash, version, ok, test.version, test.ok)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package moibit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 )
 
 const (
@@ -44,23 +46,65 @@ func BaseURL(url string) ClientOption {
 	}
 }
 
+// LazyAuth returns a ClientOption that defers the authentication of a Client until its first request.
+// The Client is constructed without a network round-trip and concurrent first requests authenticate once.
+// A failed authentication is returned by the request and attempted again by the next request.
+func LazyAuth() ClientOption {
+	return func(client *Client) error {
+		client.lazy = true
+		return nil
+	}
+}
+
+// DeveloperKey returns a ClientOption that sets the known public key of the developer for a Client.
+// The Client is constructed without authenticating its credentials, which only happens if MOIBit rejects them.
+func DeveloperKey(pubkey string) ClientOption {
+	return func(client *Client) error {
+		if pubkey == "" {
+			return fmt.Errorf("empty developer key")
+		}
+
+		client.pubkey = pubkey
+		client.authenticated = true
+		return nil
+	}
+}
+
 // Client represents a MOIBit API Client
 type Client struct {
-	c   http.Client
-	url string
+	c          *http.Client
+	url        string
+	retry      RetryPolicy
+	middleware []Middleware
+	keys       KeyProvider
+	signer     Signer
+	onReauth   func(error)
+	lazy       bool
 
-	pubkey    string
-	nonce     string
-	signature string
+	// mu guards the credentials, which are replaced by Reauthenticate.
+	// reauth serializes authentication and generation counts the replaced credentials.
+	mu            sync.RWMutex
+	reauth        sync.Mutex
+	generation    uint64
+	authenticated bool
+	pubkey        string
+	nonce         string
+	signature     string
 
 	appID string
 	netID string
 }
 
 // NewClient creates a new MOIBit API Client for the given signature and nonce
-// Accepts a variadic number of ClientOption arguments to set the App ID, Network ID or Base URL
+// Accepts a variadic number of ClientOption arguments to set the App ID, Network ID, Base URL or HTTP Client
 // Uses the DefaultNetworkID, DefaultBaseURL and no App ID, by default.
 func NewClient(signature, nonce string, opts ...ClientOption) (*Client, error) {
+	return NewClientContext(context.Background(), signature, nonce, opts...)
+}
+
+// NewClientContext creates a new MOIBit API Client for the given signature and nonce.
+// It behaves like NewClient but uses the given context for the authentication request.
+func NewClientContext(ctx context.Context, signature, nonce string, opts ...ClientOption) (*Client, error) {
 	// Generate the default client with the nonce and signature
 	client := defaultClient(signature, nonce)
 	// Apply the options on the client config
@@ -70,23 +114,32 @@ func NewClient(signature, nonce string, opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	// Wrap the transport of the client with its middleware
+	client.applyMiddleware()
+
+	// Defer authentication to the first request or skip it for a known public key
+	if client.lazy || client.authenticated {
+		return client, nil
+	}
+
 	// Authenticate credentials and get public key
-	pubkey, err := Authenticate(client)
+	pubkey, err := AuthenticateContext(ctx, client)
 	if err != nil {
 		return nil, fmt.Errorf("user could not be authenticated: %w", err)
 	}
 
 	// Set the pubkey of the client from the authenticated public key
 	client.pubkey = pubkey
+	client.authenticated = true
 	return client, nil
 }
 
 // defaultClient generates a new Client for a given public key, nonce and signature.
 func defaultClient(sig, n string) *Client {
 	return &Client{
-		http.Client{}, DefaultBaseURL,
-		"", n, sig,
-		"", DefaultNetworkID,
+		c: &http.Client{}, url: DefaultBaseURL,
+		nonce: n, signature: sig,
+		netID: DefaultNetworkID,
 	}
 }
 
@@ -100,32 +153,46 @@ func (client *Client) serviceURL(endpoint string) string {
 // Accepts the nonce and signature of the developer and returns the public or an
 // error if either the authentication routine fails or if the credentials are invalid.
 func Authenticate(client *Client) (string, error) {
+	return AuthenticateContext(context.Background(), client)
+}
+
+// AuthenticateContext attempts to authenticate a set of credentials with MOIBit.
+// It behaves like Authenticate but carries the given context on the outgoing request.
+func AuthenticateContext(ctx context.Context, client *Client) (string, error) {
 	// Create new POST request for user authentication
-	request, err := http.NewRequest("POST", client.serviceURL("/user/auth"), nil)
+	request, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/user/auth"), nil)
 	if err != nil {
 		return "", fmt.Errorf("request generation failed: %w", err)
 	}
 
 	// Set the auth headers (signature and nonce)
+	client.mu.RLock()
 	request.Header.Set("nonce", client.nonce)
 	request.Header.Set("signature", client.signature)
+	client.mu.RUnlock()
 
-	// Perform the request
-	response, err := client.c.Do(request)
+	// Perform the request, without re-authentication on rejection
+	response, err := client.send(request, true)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
 	}
 
+	defer response.Body.Close()
+
 	// Check the status code of response
 	if response.StatusCode != 200 {
-		return "", fmt.Errorf("user not authenticated: %v", response.StatusCode)
+		// Attempt to decode the error metadata from the response
+		auth := new(responseUserAuth)
+		_ = json.NewDecoder(response.Body).Decode(auth)
+
+		return "", fmt.Errorf("user not authenticated: %w", newAPIError("/user/auth", response.StatusCode, auth.Metadata))
 	}
 
 	// Decode the response into a responseUserAuth
 	auth := new(responseUserAuth)
 	decoder := json.NewDecoder(response.Body)
 	if err := decoder.Decode(auth); err != nil {
-		return "", fmt.Errorf("response decode failed: %w", err)
+		return "", newDecodeError("/user/auth", response.StatusCode, err)
 	}
 
 	return auth.Data.Address, nil
@@ -154,9 +221,46 @@ type responseUserAuth struct {
 // setHeaders accepts a HTTP Request and sets the headers
 // "developerKey", "networkID" and "appID" from the client.
 func (client *Client) setHeaders(request *http.Request) {
+	client.mu.RLock()
 	request.Header.Set("nonce", client.nonce)
 	request.Header.Set("signature", client.signature)
 	request.Header.Set("developerKey", client.pubkey)
+	client.mu.RUnlock()
+
 	request.Header.Set("networkID", client.netID)
 	request.Header.Set("appID", client.appID)
 }
+
+// credentialsCopy returns a Client with the transport and settings of the client but without credentials
+func (client *Client) credentialsCopy() *Client {
+	return &Client{
+		c: client.c, url: client.url, retry: client.retry,
+		appID: client.appID, netID: client.netID,
+	}
+}
+
+// setCredentials replaces the signature, nonce and public key of the client
+func (client *Client) setCredentials(signature, nonce, pubkey string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.signature, client.nonce, client.pubkey = signature, nonce, pubkey
+	client.authenticated = true
+	client.generation++
+}
+
+// isAuthenticated returns whether the client has a public key, either authenticated or set with DeveloperKey
+func (client *Client) isAuthenticated() bool {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	return client.authenticated
+}
+
+// credentialsGeneration returns the number of times the credentials of the client have been replaced
+func (client *Client) credentialsGeneration() uint64 {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	return client.generation
+}
@@ -0,0 +1,189 @@
+package moibit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// newTestClient starts a moibittest.Server and returns it with a Client authenticated against it.
+// The server is closed when the test finishes.
+func newTestClient(t *testing.T, opts ...moibit.ClientOption) (*moibittest.Server, *moibit.Client) {
+	t.Helper()
+
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := server.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("client could not be created: %v", err)
+	}
+
+	return server, client
+}
+
+// writeFiles writes the given files (absolute path to text) with the client
+func writeFiles(t *testing.T, client *moibit.Client, files map[string]string) {
+	t.Helper()
+
+	for name, text := range files {
+		if _, err := client.WriteFile([]byte(text), name); err != nil {
+			t.Fatalf("WriteFile(%v) failed: %v", name, err)
+		}
+	}
+}
+
+func TestLazyAuth(t *testing.T) {
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	// The client is constructed without authenticating, so invalid credentials fail the first request
+	client, err := moibit.NewClient("wrong-signature", server.Nonce, moibit.BaseURL(server.URL), moibit.LazyAuth())
+	if err != nil {
+		t.Fatalf("NewClient with LazyAuth = %v, want no authentication", err)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 0 {
+		t.Errorf("%v calls to /user/auth before the first request, want 0", calls)
+	}
+
+	if _, err := client.ListFiles("/"); !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("first ListFiles with invalid credentials = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+
+	if calls := server.Calls("/listfiles"); calls != 0 {
+		t.Errorf("%v calls to /listfiles, want the request to fail before it is sent", calls)
+	}
+
+	// A failed authentication is attempted again by the next request
+	client, err = server.NewClient(moibit.LazyAuth())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Inject(moibittest.Fault{Endpoint: "/user/auth", Status: http.StatusServiceUnavailable})
+	if _, err := client.ListFiles("/"); err == nil {
+		t.Error("first ListFiles with a failed authentication succeeded, want an error")
+	}
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Errorf("ListFiles after a failed authentication = %v, want it authenticated", err)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 3 {
+		t.Errorf("%v calls to /user/auth, want 3", calls)
+	}
+}
+
+func TestLazyAuthConcurrent(t *testing.T) {
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := server.NewClient(moibit.LazyAuth())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent first requests wait for a single slow authentication
+	server.Inject(moibittest.Fault{Endpoint: "/user/auth", Latency: 100 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = client.ListFiles("/")
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("ListFiles %v = %v, want it authenticated", i, err)
+		}
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 1 {
+		t.Errorf("%v calls to /user/auth, want 1", calls)
+	}
+}
+
+func TestDeveloperKey(t *testing.T) {
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	if _, err := server.NewClient(moibit.DeveloperKey("")); err == nil || !strings.Contains(err.Error(), "empty developer key") {
+		t.Errorf("NewClient with an empty DeveloperKey = %v, want an empty developer key error", err)
+	}
+
+	// A known public key skips the authentication
+	client, err := server.NewClient(moibit.DeveloperKey(server.DeveloperKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Errorf("ListFiles with a known DeveloperKey = %v", err)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 0 {
+		t.Errorf("%v calls to /user/auth, want 0", calls)
+	}
+
+	// A wrong public key is rejected and replaced by authenticating the credentials
+	client, err = server.NewClient(moibit.DeveloperKey("0x0000000000000000000000000000000000000002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Errorf("ListFiles with a wrong DeveloperKey = %v, want it replayed", err)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 1 {
+		t.Errorf("%v calls to /user/auth, want 1", calls)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	server, client := newTestClient(t)
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", Latency: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The request is aborted when the deadline expires instead of waiting for the slow response
+	start := time.Now()
+	if _, err := client.ListFilesContext(ctx, "/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListFilesContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ListFilesContext returned after %v, want it aborted at the deadline", elapsed)
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	server, client := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A cancelled context fails the request before it is sent
+	if _, err := client.WriteFileContext(ctx, []byte("data"), "/cancelled.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("WriteFileContext = %v, want %v", err, context.Canceled)
+	}
+
+	if calls := server.Calls("/writetexttofile"); calls != 0 {
+		t.Errorf("%v calls to /writetexttofile, want 0", calls)
+	}
+}
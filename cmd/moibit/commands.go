@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// runList lists the files in a directory
+func runList(env *environment, args []string) error {
+	flags := env.newFlagSet("ls")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	dirpath := "/"
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		dirpath = flags.Arg(0)
+	default:
+		return errUsage
+	}
+
+	files, err := env.client.ListFilesContext(env.ctx, dirpath)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.printJSON(files)
+	}
+
+	table := env.table()
+	fmt.Fprintln(table, "TYPE\tSIZE\tVERSION\tUPDATED\tNAME")
+	for _, file := range files {
+		if file.IsDirectory {
+			fmt.Fprintf(table, "dir\t-\t-\t-\t%v/\n", file.Name())
+			continue
+		}
+
+		fmt.Fprintf(table, "file\t%v\t%v\t%v\t%v\n", file.FileSize, file.Version, formatTime(file.FileVersionDescriptor), file.Name())
+	}
+
+	return table.Flush()
+}
+
+// runStat shows the status of a file or directory
+func runStat(env *environment, args []string) error {
+	flags := env.newFlagSet("stat")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	file, err := env.client.FileStatusContext(env.ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !file.Exists() {
+		return fmt.Errorf("%v: %w", flags.Arg(0), moibit.ErrNotFound)
+	}
+
+	if env.json {
+		return env.printJSON(file)
+	}
+
+	table := env.table()
+	fmt.Fprintf(table, "Path:\t%v\n", file.FullPath())
+	if file.IsDirectory {
+		fmt.Fprintf(table, "Type:\tdirectory\n")
+		return table.Flush()
+	}
+
+	fmt.Fprintf(table, "Type:\tfile\n")
+	fmt.Fprintf(table, "Size:\t%v\n", file.FileSize)
+	fmt.Fprintf(table, "Version:\t%v\n", file.Version)
+	fmt.Fprintf(table, "Hash:\t%v\n", file.Hash)
+	fmt.Fprintf(table, "Replication:\t%v\n", file.Replication)
+	fmt.Fprintf(table, "Updated:\t%v\n", formatTime(file.FileVersionDescriptor))
+	if file.ProvenanceHash != "" {
+		fmt.Fprintf(table, "Provenance:\t%v\n", file.ProvenanceHash)
+	}
+
+	return table.Flush()
+}
+
+// runVersions lists the versions of a file
+func runVersions(env *environment, args []string) error {
+	flags := env.newFlagSet("versions")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	versions, err := env.client.FileVersionsContext(env.ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.printJSON(versions)
+	}
+
+	table := env.table()
+	fmt.Fprintln(table, "VERSION\tACTIVE\tSIZE\tUPDATED\tHASH")
+	for _, version := range versions {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", version.Version, version.Active, version.FileSize, formatTime(version), version.Hash)
+	}
+
+	return table.Flush()
+}
+
+// runCat writes the data of a file to stdout
+func runCat(env *environment, args []string) error {
+	flags := env.newFlagSet("cat")
+	version := flags.Int("version", 0, "version of the file to read, the latest version if 0")
+	verify := flags.Bool("verify", false, "verify the data against the hash of the file")
+	noDecompress := flags.Bool("no-decompress", false, "write the file as it is stored if it was compressed on the client")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	opts := make([]moibit.ReadOption, 0)
+	if *verify {
+		opts = append(opts, moibit.Verify())
+	}
+
+	if *noDecompress {
+		opts = append(opts, moibit.NoDecompress())
+	}
+
+	_, err := env.client.ReadFileToContext(env.ctx, env.stdout, flags.Arg(0), *version, opts...)
+	return err
+}
+
+// encryptionTypes are the names of the EncryptionType values accepted by the put command
+var encryptionTypes = map[string]moibit.EncryptionType{
+	"none":      moibit.NoEncryption,
+	"network":   moibit.DefaultNetworkEncryption,
+	"developer": moibit.DeveloperKeyEncryption,
+	"enduser":   moibit.EndUserKeyEncryption,
+	"custom":    moibit.CustomKeyEncryption,
+	"mes":       moibit.MESEncryption,
+}
+
+// runPut writes a local file or stdin to MOIBit
+func runPut(env *environment, args []string) error {
+	flags := env.newFlagSet("put")
+	keepPrevious := flags.Bool("keep-previous", false, "keep the previous versions of the file")
+	createFolders := flags.Bool("create-folders", true, "create the folders in the path of the file that do not exist")
+	provenance := flags.Bool("provenance", false, "store the proof of the file on the provenance network")
+	replication := flags.Int("replication", 0, "replication factor of the file, the default of the app if 0")
+	encryption := flags.String("encryption", "", "server-side encryption: none, network, developer, enduser, custom or mes")
+	binary := flags.Bool("binary", false, "upload the file as binary data even if it is valid UTF-8 text")
+	retry := flags.Bool("retry-write", false, "allow the write to be retried by the retry policy")
+	compress := flags.Bool("compress", false, "compress the file with gzip on the client")
+	stream := flags.Bool("stream", false, "stream the file instead of reading it into memory (excludes -binary and -retry-write)")
+	progress := flags.Bool("progress", false, "report the progress of the upload on stderr (requires -stream)")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	// Progress is only reported for streamed uploads, which are always binary and never retried
+	if flags.NArg() != 2 || (*progress && !*stream) || (*stream && (*binary || *retry)) {
+		return errUsage
+	}
+
+	encryptionType, ok := encryptionTypes[strings.ToLower(*encryption)]
+	if *encryption != "" && !ok {
+		return errUsage
+	}
+
+	// Collect the write options from the flags
+	opts := make([]moibit.WriteOption, 0)
+	if *keepPrevious {
+		opts = append(opts, moibit.KeepPrevious())
+	}
+
+	if *createFolders {
+		opts = append(opts, moibit.CreateFolders())
+	} else {
+		opts = append(opts, moibit.CreateOnlyFile())
+	}
+
+	if *provenance {
+		opts = append(opts, moibit.Provenance())
+	}
+
+	if *replication != 0 {
+		opts = append(opts, moibit.ReplicationFactor(*replication))
+	}
+
+	if *encryption != "" {
+		opts = append(opts, moibit.ApplyEncryption(encryptionType))
+	}
+
+	if *binary {
+		opts = append(opts, moibit.BinaryUpload())
+	}
+
+	if *retry {
+		opts = append(opts, moibit.RetryWrite())
+	}
+
+	if *compress {
+		opts = append(opts, moibit.Compress(moibit.GzipCompression))
+	}
+
+	if *progress {
+		opts = append(opts, moibit.Progress(func(sent int64) {
+			fmt.Fprintf(env.stderr, "\r%v bytes sent", sent)
+		}))
+	}
+
+	// Open the local file or stdin
+	local, remote := flags.Arg(0), flags.Arg(1)
+
+	source := env.stdin
+	if local != "-" {
+		file, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		source = file
+	}
+
+	var (
+		file moibit.FileDescriptor
+		err  error
+	)
+
+	if *stream {
+		file, err = env.client.WriteFrom(env.ctx, source, remote, opts...)
+	} else {
+		var data []byte
+		if data, err = io.ReadAll(source); err != nil {
+			return err
+		}
+
+		file, err = env.client.WriteFileContext(env.ctx, data, remote, opts...)
+	}
+
+	if *progress {
+		fmt.Fprintln(env.stderr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.printJSON(file)
+	}
+
+	fmt.Fprintf(env.stdout, "%v\tversion %v\t%v bytes\t%v\n", file.FullPath(), file.Version, file.FileSize, file.Hash)
+	return nil
+}
+
+// runRemove removes a file or directory
+func runRemove(env *environment, args []string) error {
+	flags := env.newFlagSet("rm")
+	version := flags.Int("version", 0, "version of the file to remove")
+	directory := flags.Bool("dir", false, "remove a directory")
+	retry := flags.Bool("retry-remove", false, "allow the removal to be retried by the retry policy")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	opts := make([]moibit.RemoveOption, 0)
+	if *directory {
+		opts = append(opts, moibit.RemoveDirectory())
+	}
+
+	if *retry {
+		opts = append(opts, moibit.RetryRemove())
+	}
+
+	return env.client.RemoveFileContext(env.ctx, flags.Arg(0), *version, opts...)
+}
+
+// runRestore restores a version of a removed file
+func runRestore(env *environment, args []string) error {
+	flags := env.newFlagSet("restore")
+	version := flags.Int("version", 0, "version of the file to restore")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || *version == 0 {
+		return errUsage
+	}
+
+	return env.client.RemoveFileContext(env.ctx, flags.Arg(0), *version, moibit.PerformRestore())
+}
+
+// runMakeDirectory creates a directory
+func runMakeDirectory(env *environment, args []string) error {
+	flags := env.newFlagSet("mkdir")
+	retry := flags.Bool("retry-mkdir", false, "allow the creation to be retried by the retry policy")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	opts := make([]moibit.MakeDirOption, 0)
+	if *retry {
+		opts = append(opts, moibit.RetryMakeDir())
+	}
+
+	return env.client.MakeDirectoryContext(env.ctx, flags.Arg(0), opts...)
+}
+
+// runApp shows the details of the app
+func runApp(env *environment, args []string) error {
+	flags := env.newFlagSet("app")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errUsage
+	}
+
+	app, err := env.client.AppDetailsContext(env.ctx)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.printJSON(app)
+	}
+
+	table := env.table()
+	fmt.Fprintf(table, "ID:\t%v\n", app.AppID)
+	fmt.Fprintf(table, "Name:\t%v\n", app.AppName)
+	fmt.Fprintf(table, "Description:\t%v\n", app.AppDescription)
+	fmt.Fprintf(table, "Active:\t%v\n", app.IsActive)
+	fmt.Fprintf(table, "Network:\t%v (%v)\n", app.NetworkName, app.NetworkID)
+	fmt.Fprintf(table, "Replication:\t%v\n", app.Replication)
+	fmt.Fprintf(table, "Encryption:\t%v\n", app.EncryptionType)
+	return table.Flush()
+}
+
+// runDev shows the details of the developer
+func runDev(env *environment, args []string) error {
+	flags := env.newFlagSet("dev")
+	if err := env.parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errUsage
+	}
+
+	dev, err := env.client.DevDetailsContext(env.ctx)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.printJSON(dev)
+	}
+
+	table := env.table()
+	fmt.Fprintf(table, "Name:\t%v\n", dev.Name)
+	fmt.Fprintf(table, "Email:\t%v\n", dev.Email)
+	fmt.Fprintf(table, "Active:\t%v\n", dev.Active)
+	fmt.Fprintf(table, "Apps:\t%v\n", len(dev.Apps))
+	for _, app := range dev.Apps {
+		fmt.Fprintf(table, "\t%v\t%v\tactive: %v\n", app.AppID, app.AppName, app.IsActive)
+	}
+
+	return table.Flush()
+}
+
+// table returns a tabwriter for aligned output to the stdout of the environment
+func (env *environment) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+}
+
+// printJSON prints the given value as indented JSON to the stdout of the environment
+func (env *environment) printJSON(value interface{}) error {
+	encoder := json.NewEncoder(env.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,228 @@
+// Command moibit is a command-line client for the MOIBit decentralized storage API.
+//
+// Usage:
+//
+//	moibit [global flags] <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	ls        list the files in a directory
+//	stat      show the status of a file or directory
+//	versions  list the versions of a file
+//	cat       write the data of a file to stdout
+//	put       write a local file or stdin to MOIBit
+//	rm        remove a file or directory
+//	restore   restore a version of a removed file
+//	mkdir     create a directory
+//	app       show the details of the app
+//	dev       show the details of the developer
+//
+// Run "moibit <command> -h" for the flags of a command.
+//
+// The credentials and settings of the client are read from a profile of the configuration file
+// (~/.config/moibit/config.toml or $MOIBIT_CONFIG), overridden by the MOIBIT_* environment
+// variables and by the global flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	usage   string
+	summary string
+	run     func(env *environment, args []string) error
+}
+
+// commands are the subcommands of the CLI by their name
+var commands = map[string]command{
+	"ls":       {"ls [path]", "list the files in a directory", runList},
+	"stat":     {"stat <path>", "show the status of a file or directory", runStat},
+	"versions": {"versions <path>", "list the versions of a file", runVersions},
+	"cat":      {"cat [flags] <path>", "write the data of a file to stdout", runCat},
+	"put":      {"put [flags] <local|-> <remote>", "write a local file or stdin to MOIBit", runPut},
+	"rm":       {"rm [flags] <path>", "remove a file or directory", runRemove},
+	"restore":  {"restore -version <n> <path>", "restore a version of a removed file", runRestore},
+	"mkdir":    {"mkdir [flags] <path>", "create a directory", runMakeDirectory},
+	"app":      {"app", "show the details of the app", runApp},
+	"dev":      {"dev", "show the details of the developer", runDev},
+}
+
+// environment is the state shared by the subcommands
+type environment struct {
+	ctx    context.Context
+	client *moibit.Client
+	json   bool
+	usage  string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage is returned by a subcommand when it is invoked with invalid arguments
+var errUsage = errors.New("invalid usage")
+
+// errFlags is returned by a subcommand when its flags are invalid, after the FlagSet printed the error and usage
+var errFlags = errors.New("invalid flags")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+
+	os.Exit(code)
+}
+
+// run executes the CLI with the given arguments and returns its exit code:
+// 0 on success, 1 if the command fails and 2 if the command is invoked with invalid arguments.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("moibit", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { usage(flags) }
+
+	profileName := flags.String("profile", "", "profile of the configuration file to use (default $MOIBIT_PROFILE or the default profile)")
+	signature := flags.String("signature", "", "signature of the developer, overrides the profile")
+	nonce := flags.String("nonce", "", "nonce of the signature, overrides the profile")
+	appID := flags.String("app", "", "ID of the app, overrides the profile")
+	networkID := flags.String("network", "", "ID of the network, overrides the profile")
+	baseURL := flags.String("url", "", "base URL of the MOIBit API, overrides the profile")
+	timeout := flags.Duration("timeout", 0, "timeout of the command, no timeout if 0")
+	retry := flags.Bool("retry", false, "retry failed requests with the default retry policy")
+	output := flags.Bool("json", false, "print the output as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		usage(flags)
+		return 2
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "moibit: unknown command %q\n", name)
+		usage(flags)
+		return 2
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// Load the profile and override it with the flags that are set
+	profile, err := moibit.LoadProfile(*profileName)
+	if err != nil {
+		fmt.Fprintf(stderr, "moibit: %v\n", err)
+		return 1
+	}
+
+	overrides := map[string]struct{ flag, value *string }{
+		"signature": {signature, &profile.Signature},
+		"nonce":     {nonce, &profile.Nonce},
+		"app":       {appID, &profile.AppID},
+		"network":   {networkID, &profile.NetworkID},
+		"url":       {baseURL, &profile.BaseURL},
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if override, ok := overrides[f.Name]; ok {
+			*override.value = *override.flag
+		}
+	})
+
+	// Create the client, which authenticates with its first request so that
+	// the arguments of the command are validated before any request is performed
+	opts := append(profile.ClientOptions(), moibit.LazyAuth())
+	if *retry {
+		opts = append(opts, moibit.Retry(moibit.DefaultRetryPolicy()))
+	}
+
+	client, err := moibit.NewClientContext(ctx, profile.Signature, profile.Nonce, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "moibit: %v\n", err)
+		return 1
+	}
+
+	env := &environment{ctx: ctx, client: client, json: *output, usage: cmd.usage, stdin: stdin, stdout: stdout, stderr: stderr}
+	if err := cmd.run(env, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, errFlags) || errors.Is(err, flag.ErrHelp) {
+			if errors.Is(err, errUsage) {
+				fmt.Fprintf(stderr, "usage: moibit %v\n", cmd.usage)
+			}
+
+			return 2
+		}
+
+		fmt.Fprintf(stderr, "moibit %v: %v\n", name, err)
+		return 1
+	}
+
+	return 0
+}
+
+// usage prints the usage of the CLI with its global flags and commands
+func usage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintf(out, "usage: moibit [global flags] <command> [flags] [arguments]\n\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-9v %v\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintf(out, "\nglobal flags:\n")
+	flags.PrintDefaults()
+}
+
+// newFlagSet returns the FlagSet of the given subcommand, printing its usage to the stderr of the environment
+func (env *environment) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: moibit %v\n", env.usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parse parses the arguments of a subcommand with its FlagSet, reporting invalid flags as errFlags
+func (env *environment) parse(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errFlags
+	}
+
+	return err
+}
+
+// formatTime formats the time the given file version was last updated for display,
+// returning its timestamp as is if it cannot be parsed
+func formatTime(version moibit.FileVersionDescriptor) string {
+	updated := version.LastUpdatedTime()
+	if updated.IsZero() {
+		return version.LastUpdated
+	}
+
+	return updated.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// newTestCLI starts a moibittest.Server and returns it with a function that runs the CLI against it.
+// The configuration file and the MOIBIT_* environment variables are isolated from the test environment.
+func newTestCLI(t *testing.T) (*moibittest.Server, func(stdin string, args ...string) (int, string, string)) {
+	t.Helper()
+
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	t.Setenv(moibit.EnvConfig, filepath.Join(t.TempDir(), "missing.toml"))
+	for _, variable := range []string{moibit.EnvProfile, moibit.EnvSignature, moibit.EnvNonce, moibit.EnvAppID, moibit.EnvNetworkID, moibit.EnvBaseURL} {
+		t.Setenv(variable, "")
+	}
+
+	credentials := []string{"-url", server.URL, "-signature", server.Signature, "-nonce", server.Nonce, "-app", "cli"}
+
+	return server, func(stdin string, args ...string) (int, string, string) {
+		t.Helper()
+
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), append(append([]string(nil), credentials...), args...), strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+}
+
+// decode decodes the JSON output of a command into the given value or fails the test
+func decode(t *testing.T, output string, value interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(output), value); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%v", err, output)
+	}
+}
+
+func TestPutAndCat(t *testing.T) {
+	_, cli := newTestCLI(t)
+
+	// put reads the data from stdin with -
+	code, stdout, stderr := cli("hello world", "put", "-", "/docs/hello.txt")
+	if code != 0 {
+		t.Fatalf("put exited with %v: %v", code, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "/docs/hello.txt\tversion 1\t11 bytes\t") {
+		t.Errorf("put output = %q, want the path, version and size of the file", stdout)
+	}
+
+	// put reads the data from a local file
+	local := filepath.Join(t.TempDir(), "local.txt")
+	if err := os.WriteFile(local, []byte("local data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr = cli("", "-json", "put", "-keep-previous", local, "/docs/hello.txt")
+	if code != 0 {
+		t.Fatalf("put -json exited with %v: %v", code, stderr)
+	}
+
+	var file moibit.FileDescriptor
+	decode(t, stdout, &file)
+	if file.FullPath() != "/docs/hello.txt" || file.Version != 2 || file.FileSize != len("local data") {
+		t.Errorf("put -json = %+v, want version 2 of /docs/hello.txt", file)
+	}
+
+	for version, want := range map[string]string{"0": "local data", "1": "hello world"} {
+		if code, stdout, stderr := cli("", "cat", "-version", version, "-verify", "/docs/hello.txt"); code != 0 || stdout != want {
+			t.Errorf("cat -version %v = %q, exit %v: %v, want %q", version, stdout, code, stderr, want)
+		}
+	}
+
+	// Compressed files are decompressed unless -no-decompress is given
+	if code, _, stderr := cli(strings.Repeat("compressible ", 100), "put", "-compress", "-", "/logs/app.log"); code != 0 {
+		t.Fatalf("put -compress exited with %v: %v", code, stderr)
+	}
+
+	if _, stdout, _ := cli("", "cat", "/logs/app.log"); stdout != strings.Repeat("compressible ", 100) {
+		t.Errorf("cat of a compressed file = %q, want the written data", stdout)
+	}
+
+	if _, stdout, _ := cli("", "cat", "-no-decompress", "/logs/app.log"); !strings.HasPrefix(stdout, "MBZ1") {
+		t.Errorf("cat -no-decompress = %q, want the stored data", stdout)
+	}
+}
+
+func TestListAndStat(t *testing.T) {
+	_, cli := newTestCLI(t)
+	cli("alpha", "put", "-", "/a.txt")
+	cli("bravo", "put", "-", "/dir/b.txt")
+
+	code, stdout, stderr := cli("", "ls")
+	if code != 0 {
+		t.Fatalf("ls exited with %v: %v", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || strings.Fields(lines[0])[0] != "TYPE" {
+		t.Fatalf("ls output = %q, want a header and 2 entries", stdout)
+	}
+
+	rows := map[string][]string{}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		rows[fields[len(fields)-1]] = fields
+	}
+
+	if row := rows["a.txt"]; row == nil || row[0] != "file" || row[1] != "5" || row[2] != "1" {
+		t.Errorf("ls entry of a.txt = %q, want a file of 5 bytes at version 1", row)
+	}
+
+	if row := rows["dir/"]; row == nil || row[0] != "dir" {
+		t.Errorf("ls entry of dir = %q, want a directory", row)
+	}
+
+	code, stdout, _ = cli("", "-json", "ls", "/dir")
+	var files []moibit.FileDescriptor
+	decode(t, stdout, &files)
+	if code != 0 || len(files) != 1 || files[0].FullPath() != "/dir/b.txt" {
+		t.Errorf("ls -json /dir = %+v, want /dir/b.txt", files)
+	}
+
+	// stat shows the status of files and directories
+	code, stdout, stderr = cli("", "stat", "/a.txt")
+	if code != 0 {
+		t.Fatalf("stat exited with %v: %v", code, stderr)
+	}
+
+	for _, want := range []string{"Path:", "/a.txt", "Type:", "file", "Size:", "5", "Version:", "Hash:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stat output = %q, want it to contain %q", stdout, want)
+		}
+	}
+
+	if _, stdout, _ := cli("", "stat", "/dir"); !strings.Contains(stdout, "directory") {
+		t.Errorf("stat output of a directory = %q, want its type", stdout)
+	}
+
+	code, stdout, _ = cli("", "-json", "stat", "/dir/b.txt")
+	var file moibit.FileDescriptor
+	decode(t, stdout, &file)
+	if code != 0 || file.FullPath() != "/dir/b.txt" || file.FileSize != 5 {
+		t.Errorf("stat -json = %+v, want /dir/b.txt of 5 bytes", file)
+	}
+
+	if code, _, stderr := cli("", "stat", "/missing.txt"); code != 1 || !strings.Contains(stderr, "moibit stat:") {
+		t.Errorf("stat of a missing file exited with %v: %q, want 1 and an error", code, stderr)
+	}
+}
+
+func TestVersionsRemoveAndRestore(t *testing.T) {
+	_, cli := newTestCLI(t)
+	cli("v1", "put", "-", "/doc.txt")
+	cli("v2", "put", "-keep-previous", "-", "/doc.txt")
+
+	code, stdout, stderr := cli("", "versions", "/doc.txt")
+	if code != 0 {
+		t.Fatalf("versions exited with %v: %v", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || strings.Fields(lines[0])[0] != "VERSION" {
+		t.Errorf("versions output = %q, want a header and 2 versions", stdout)
+	}
+
+	// rm removes the latest version and restore brings it back
+	if code, _, stderr := cli("", "rm", "-version", "2", "/doc.txt"); code != 0 {
+		t.Fatalf("rm exited with %v: %v", code, stderr)
+	}
+
+	if _, stdout, _ := cli("", "cat", "/doc.txt"); stdout != "v1" {
+		t.Errorf("cat after rm = %q, want %q", stdout, "v1")
+	}
+
+	if code, _, stderr := cli("", "restore", "-version", "2", "/doc.txt"); code != 0 {
+		t.Fatalf("restore exited with %v: %v", code, stderr)
+	}
+
+	if _, stdout, _ := cli("", "cat", "/doc.txt"); stdout != "v2" {
+		t.Errorf("cat after restore = %q, want %q", stdout, "v2")
+	}
+
+	code, stdout, _ = cli("", "-json", "versions", "/doc.txt")
+	var versions []moibit.FileVersionDescriptor
+	decode(t, stdout, &versions)
+	if code != 0 || len(versions) != 2 || versions[0].Version+versions[1].Version != 3 {
+		t.Errorf("versions -json = %+v, want versions 1 and 2", versions)
+	}
+
+	// mkdir creates a directory that rm only removes with -dir
+	if code, _, stderr := cli("", "mkdir", "/empty"); code != 0 {
+		t.Fatalf("mkdir exited with %v: %v", code, stderr)
+	}
+
+	if code, _, _ := cli("", "rm", "/empty"); code != 1 {
+		t.Errorf("rm of a directory without -dir exited with %v, want 1", code)
+	}
+
+	if code, _, stderr := cli("", "rm", "-dir", "/empty"); code != 0 {
+		t.Errorf("rm -dir exited with %v: %v", code, stderr)
+	}
+}
+
+func TestAppAndDev(t *testing.T) {
+	_, cli := newTestCLI(t)
+	cli("data", "put", "-", "/a.txt")
+
+	code, stdout, stderr := cli("", "app")
+	if code != 0 || !strings.Contains(stdout, "ID:") || !strings.Contains(stdout, "cli") {
+		t.Errorf("app output = %q, exit %v: %v, want the details of the app", stdout, code, stderr)
+	}
+
+	code, stdout, _ = cli("", "-json", "app")
+	var app moibit.AppDescriptor
+	decode(t, stdout, &app)
+	if code != 0 || app.AppID != "cli" {
+		t.Errorf("app -json = %+v, want the app cli", app)
+	}
+
+	code, stdout, stderr = cli("", "dev")
+	if code != 0 || !strings.Contains(stdout, "Email:") || !strings.Contains(stdout, "dev@moibittest.local") {
+		t.Errorf("dev output = %q, exit %v: %v, want the details of the developer", stdout, code, stderr)
+	}
+
+	code, stdout, _ = cli("", "-json", "dev")
+	var dev moibit.DevDescriptor
+	decode(t, stdout, &dev)
+	if code != 0 || dev.Email != "dev@moibittest.local" || len(dev.Apps) != 1 || dev.Apps[0].AppID != "cli" {
+		t.Errorf("dev -json = %+v, want the developer with the app cli", dev)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	_, cli := newTestCLI(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "usage: moibit [global flags]"},
+		{"unknown command", []string{"copy"}, `unknown command "copy"`},
+		{"unknown global flag", []string{"-unknown", "ls"}, "flag provided but not defined"},
+		{"unknown flag", []string{"cat", "-unknown", "/a.txt"}, "flag provided but not defined"},
+		{"invalid flag value", []string{"rm", "-version", "latest", "/a.txt"}, "invalid value"},
+		{"missing argument", []string{"cat"}, "usage: moibit cat [flags] <path>"},
+		{"extra argument", []string{"ls", "/a", "/b"}, "usage: moibit ls [path]"},
+		{"put without remote", []string{"put", "-"}, "usage: moibit put"},
+		{"put progress without stream", []string{"put", "-progress", "-", "/a.txt"}, "usage: moibit put"},
+		{"put stream with binary", []string{"put", "-stream", "-binary", "-", "/a.txt"}, "usage: moibit put"},
+		{"put stream with retry", []string{"put", "-stream", "-retry-write", "-", "/a.txt"}, "usage: moibit put"},
+		{"put unknown encryption", []string{"put", "-encryption", "secret", "-", "/a.txt"}, "usage: moibit put"},
+		{"restore without version", []string{"restore", "/a.txt"}, "usage: moibit restore"},
+		{"app with argument", []string{"app", "extra"}, "usage: moibit app"},
+	}
+
+	for _, test := range tests {
+		code, stdout, stderr := cli("", test.args...)
+		if code != 2 || stdout != "" || !strings.Contains(stderr, test.want) {
+			t.Errorf("%v: exit %v, stderr %q, want 2 and %q", test.name, code, stderr, test.want)
+		}
+	}
+
+	// Failures of the command are not usage errors
+	if code, _, stderr := cli("", "put", filepath.Join(t.TempDir(), "missing.txt"), "/a.txt"); code != 1 || !strings.Contains(stderr, "moibit put:") {
+		t.Errorf("put of a missing local file exited with %v: %q, want 1 and an error", code, stderr)
+	}
+}
+
+func TestAuthenticationFailure(t *testing.T) {
+	server, _ := newTestCLI(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-url", server.URL, "-signature", "wrong", "-nonce", server.Nonce, "ls"}
+	if code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "moibit ls: ") {
+		t.Errorf("ls with a wrong signature exited with %v: %q, want 1 and an error", code, stderr.String())
+	}
+
+	// Invalid arguments are reported before the client authenticates
+	stderr.Reset()
+	if code := run(context.Background(), append(args, "-bogus"), strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("ls -bogus with a wrong signature exited with %v: %q, want 2", code, stderr.String())
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 1 {
+		t.Errorf("%v calls to /user/auth, want 1", calls)
+	}
+}
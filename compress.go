@@ -0,0 +1,314 @@
+package moibit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// CompressionType represents an enumeration for the
+// types of compression that can be applied on the client
+type CompressionType int
+
+const (
+	// NoCompression stores the file data as it is given
+	NoCompression CompressionType = iota
+
+	// GzipCompression compresses the file data with gzip at the default compression level
+	GzipCompression
+)
+
+// Compress returns a WriteOption that can be used to compress the file data on the client before it is written.
+// The compressed data is stored with a small header, which is detected by ReadFile, OpenFile and ReadFileTo to
+// decompress the data transparently. Compression is skipped for content that is already compressed, detected by
+// the extension of the file name or by sniffing the data (images, audio, video and archives). WriteFile also stores
+// the data as it is if it does not shrink. Compression is applied before encryption with ClientEncryption.
+func Compress(compression CompressionType) WriteOption {
+	return func(request *requestWriteFile) error {
+		if compression != NoCompression && compression != GzipCompression {
+			return fmt.Errorf("unsupported compression type: %v", compression)
+		}
+
+		request.compression = compression
+		return nil
+	}
+}
+
+// NoDecompress returns a ReadOption that can be used to read the data of files compressed on the client with
+// the Compress WriteOption as it is stored, without decompressing it. Unlike Raw, the data is still decrypted.
+func NoDecompress() ReadOption {
+	return func(request *requestReadFile) error {
+		request.noDecompress = true
+		return nil
+	}
+}
+
+// decompresses returns whether the given ReadOption decompress the data of the files that are read
+func decompresses(opts []ReadOption) bool {
+	request := new(requestReadFile)
+	for _, opt := range opts {
+		_ = opt(request)
+	}
+
+	return !request.noDecompress && !request.raw
+}
+
+// compressionMagic is the magic number at the start of the header of compressed files.
+// The header is the magic number followed by the byte of the CompressionType.
+var compressionMagic = []byte("MBZ1")
+
+// gzipMagic is the magic number at the start of every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressedExtensions are the file extensions of formats that are already compressed
+var compressedExtensions = map[string]bool{
+	".gz": true, ".tgz": true, ".zip": true, ".zst": true, ".bz2": true, ".xz": true, ".7z": true, ".rar": true, ".br": true, ".lz4": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true, ".heic": true,
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".opus": true, ".flac": true,
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true,
+	".docx": true, ".xlsx": true, ".pptx": true, ".jar": true, ".apk": true, ".woff": true, ".woff2": true,
+}
+
+// compressible returns whether the data of a file with the given name and leading bytes is worth compressing
+func compressible(name string, head []byte) bool {
+	if compressedExtensions[strings.ToLower(path.Ext(name))] {
+		return false
+	}
+
+	if bytes.HasPrefix(head, compressionMagic) || bytes.HasPrefix(head, encryptionMagic) {
+		return false
+	}
+
+	// Uncompressed bitmaps and waves are sniffed as images and audio as well
+	switch contentType := http.DetectContentType(head); {
+	case contentType == "image/bmp" || contentType == "audio/wave":
+		return true
+	case strings.HasPrefix(contentType, "image/"), strings.HasPrefix(contentType, "audio/"), strings.HasPrefix(contentType, "video/"):
+		return false
+	case contentType == "application/x-gzip", contentType == "application/zip", contentType == "application/x-rar-compressed":
+		return false
+	case strings.HasPrefix(contentType, "font/woff"):
+		return false
+	default:
+		return true
+	}
+}
+
+// compressData returns the given file data compressed with the CompressionType of the request and whether it was compressed.
+// Returns the data as it is and false if it is not compressible or does not shrink.
+func compressData(data []byte, request *requestWriteFile) ([]byte, bool, error) {
+	if request.compression == NoCompression || !compressible(request.FileName, data) {
+		return data, false, nil
+	}
+
+	compressed, err := io.ReadAll(newCompressReader(bytes.NewReader(data), request.compression, int64(len(data))))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(compressed) >= len(data) {
+		return data, false, nil
+	}
+
+	return compressed, true, nil
+}
+
+// compressStream returns a reader of the given file data compressed with the CompressionType of the request.
+// Returns a reader of the data as it is if it is not compressible.
+func compressStream(r io.Reader, request *requestWriteFile) io.Reader {
+	if request.compression == NoCompression {
+		return r
+	}
+
+	// Sniff the leading bytes of the data without consuming them
+	buffered := bufio.NewReader(r)
+	head, _ := buffered.Peek(512)
+
+	if !compressible(request.FileName, head) {
+		return buffered
+	}
+
+	return newCompressReader(buffered, request.compression, -1)
+}
+
+// compressReader is an io.Reader of the header and compressed data of the data read from the source.
+// The data is compressed as it is read, without a background goroutine.
+type compressReader struct {
+	src     io.Reader
+	writer  *gzip.Writer
+	pending *bytes.Buffer
+	chunk   []byte
+	done    bool
+}
+
+// newCompressReader returns a compressReader for the given source with the given CompressionType.
+// The size of the source is recorded in the gzip header if it is known (not negative).
+func newCompressReader(src io.Reader, compression CompressionType, size int64) *compressReader {
+	pending := bytes.NewBuffer(append(append([]byte(nil), compressionMagic...), byte(compression)))
+
+	writer := gzip.NewWriter(pending)
+	if size >= 0 {
+		writer.Header.Extra = sizeField(size)
+	}
+
+	return &compressReader{src: src, writer: writer, pending: pending, chunk: make([]byte, 32<<10)}
+}
+
+// sizeSubfield is the ID of the subfield of the extra field of the gzip header that records the uncompressed size
+var sizeSubfield = [2]byte{'M', 'S'}
+
+// sizeField returns the extra field of a gzip header that records the given uncompressed size.
+// It is a single subfield (RFC 1952) with the little endian 64-bit size.
+func sizeField(size int64) []byte {
+	field := append(sizeSubfield[:], 8, 0)
+	return binary.LittleEndian.AppendUint64(field, uint64(size))
+}
+
+// parseSizeField returns the uncompressed size recorded in the given extra field of a gzip header.
+// Returns -1 if the size is not recorded.
+func parseSizeField(extra []byte) int64 {
+	for len(extra) >= 4 {
+		length := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+length {
+			break
+		}
+
+		if extra[0] == sizeSubfield[0] && extra[1] == sizeSubfield[1] && length == 8 {
+			return int64(binary.LittleEndian.Uint64(extra[4:12]))
+		}
+
+		extra = extra[4+length:]
+	}
+
+	return -1
+}
+
+// Read implements the io.Reader interface for compressReader
+func (reader *compressReader) Read(p []byte) (int, error) {
+	for reader.pending.Len() == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		n, err := reader.src.Read(reader.chunk)
+		if n > 0 {
+			if _, werr := reader.writer.Write(reader.chunk[:n]); werr != nil {
+				return 0, werr
+			}
+		}
+
+		if err == io.EOF {
+			if cerr := reader.writer.Close(); cerr != nil {
+				return 0, cerr
+			}
+
+			reader.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	return reader.pending.Read(p)
+}
+
+// compressionHeaderSize is the size of the header of compressed files, including the gzip magic number
+var compressionHeaderSize = len(compressionMagic) + 1 + len(gzipMagic)
+
+// isCompressed returns whether the given reader starts with the header of a compressed file
+func isCompressed(r *bufio.Reader) bool {
+	head, _ := r.Peek(compressionHeaderSize)
+	return len(head) == compressionHeaderSize &&
+		bytes.HasPrefix(head, compressionMagic) && CompressionType(head[len(compressionMagic)]) == GzipCompression &&
+		bytes.HasSuffix(head, gzipMagic)
+}
+
+// isCompressedFile returns whether the latest version of the file at the given path on MOIBit is stored with the
+// header of a compressed file. Only the header is read from the stored data, which is not decrypted.
+func (client *Client) isCompressedFile(ctx context.Context, path string) (bool, error) {
+	file, err := client.OpenFileContext(ctx, path, 0, Raw())
+	if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+	return isCompressed(bufio.NewReaderSize(file, 16)), nil
+}
+
+// isCompressedLocalFile returns whether the local file at the given path starts with the header of a compressed file
+func isCompressedLocalFile(localpath string) bool {
+	file, err := os.Open(localpath)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+	return isCompressed(bufio.NewReaderSize(file, 16))
+}
+
+// maxCompressionRatio is the highest ratio of the uncompressed to the compressed size that deflate can achieve
+const maxCompressionRatio = 1032
+
+// decompressFile replaces the data of the given FileReader with its decompressed data if it starts with a compression header.
+// The Size of the FileReader is updated to the uncompressed size recorded in the header, which is unknown (-1)
+// for files written with WriteFrom. The recorded size is rejected if it cannot be the size of the compressed data,
+// and the decompressed data fails with an error once it is found to differ from the recorded size.
+func decompressFile(file *FileReader) error {
+	buffered := bufio.NewReader(file.ReadCloser)
+	if !isCompressed(buffered) {
+		file.ReadCloser = readCloser{buffered, file.ReadCloser}
+		return nil
+	}
+
+	if _, err := buffered.Discard(len(compressionMagic) + 1); err != nil {
+		return err
+	}
+
+	decompressed, err := gzip.NewReader(buffered)
+	if err != nil {
+		return err
+	}
+
+	size := parseSizeField(decompressed.Header.Extra)
+	if size < -1 || (file.Size >= 0 && size/maxCompressionRatio > file.Size) {
+		return fmt.Errorf("recorded uncompressed size %v is impossible for %v bytes of compressed data", size, file.Size)
+	}
+
+	var data io.Reader = decompressed
+	if size >= 0 {
+		data = &sizedReader{r: decompressed, size: size}
+	}
+
+	file.ReadCloser = readCloser{data, file.ReadCloser}
+	file.Size = size
+	return nil
+}
+
+// sizedReader is an io.Reader that fails if the data read from its source is not of the expected size
+type sizedReader struct {
+	r    io.Reader
+	size int64
+	read int64
+}
+
+// Read implements the io.Reader interface for sizedReader
+func (reader *sizedReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+	reader.read += int64(n)
+
+	switch {
+	case reader.read > reader.size:
+		return n, fmt.Errorf("decompressed data exceeds the recorded size of %v bytes", reader.size)
+	case err == io.EOF && reader.read != reader.size:
+		return n, fmt.Errorf("decompressed data of %v bytes does not match the recorded size of %v bytes", reader.read, reader.size)
+	default:
+		return n, err
+	}
+}
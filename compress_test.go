@@ -0,0 +1,243 @@
+package moibit_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// logData returns compressible JSON lines of at least the given size
+func logData(size int) []byte {
+	var buffer bytes.Buffer
+	for i := 0; buffer.Len() < size; i++ {
+		fmt.Fprintf(&buffer, `{"line": %v, "level": "info", "message": "request served"}`+"\n", i)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	_, client := newTestClient(t)
+	gzip := moibit.Compress(moibit.GzipCompression)
+
+	data := logData(400000)
+	file, err := client.WriteFile(data, "/logs/app.json", gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.FileSize >= len(data)/4 {
+		t.Errorf("compressed size = %v, want less than a quarter of %v", file.FileSize, len(data))
+	}
+
+	// The data is stored with the compression header and read as it is with NoDecompress
+	stored, err := client.ReadFile("/logs/app.json", 0, moibit.NoDecompress())
+	if err != nil || !bytes.HasPrefix(stored, []byte("MBZ1")) || len(stored) != file.FileSize {
+		t.Errorf("stored data = %v bytes, %v, want the %v bytes of compressed data", len(stored), err, file.FileSize)
+	}
+
+	read, err := client.ReadFile("/logs/app.json", 0)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("ReadFile = %v bytes, %v, want the written data", len(read), err)
+	}
+
+	// The uncompressed size is recorded by WriteFile but unknown for streamed files
+	if _, err := client.WriteFrom(context.Background(), bytes.NewReader(data), "/logs/stream.json", gzip); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]int64{"/logs/app.json": int64(len(data)), "/logs/stream.json": -1} {
+		reader, err := client.OpenFile(name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buffer bytes.Buffer
+		_, err = buffer.ReadFrom(reader)
+		reader.Close()
+
+		if reader.Size != want || err != nil || !bytes.Equal(buffer.Bytes(), data) {
+			t.Errorf("OpenFile(%v) = %v bytes of size %v, %v, want the written data of size %v", name, buffer.Len(), reader.Size, err, want)
+		}
+	}
+}
+
+func TestCompressStoredUntouched(t *testing.T) {
+	_, client := newTestClient(t)
+	gzip := moibit.Compress(moibit.GzipCompression)
+
+	var archive bytes.Buffer
+	if _, err := client.WriteFile(logData(1000), "/logs/app.json", gzip); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ReadFileTo(&archive, "/logs/app.json", 0, moibit.Raw()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"/random.bin", randomData(4096)},
+		{"/image.png", logData(4096)},
+		{"/png.bin", append([]byte("\x89PNG\r\n\x1a\n"), logData(4096)...)},
+		{"/archive.bin", archive.Bytes()[5:]},
+		{"/tiny.txt", []byte("a")},
+	}
+
+	for _, test := range tests {
+		file, err := client.WriteFile(test.data, test.name, gzip)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := client.ReadFile(test.name, 0)
+		if err != nil || !bytes.Equal(stored, test.data) || file.FileSize != len(test.data) {
+			t.Errorf("%v is not stored untouched: %v bytes stored, %v", test.name, file.FileSize, err)
+		}
+	}
+}
+
+func TestNoDecompress(t *testing.T) {
+	_, client := newTestClient(t)
+
+	data := logData(1000)
+	file, err := client.WriteFile(data, "/logs/app.json", moibit.Compress(moibit.GzipCompression))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compressed files are read as they are stored with NoDecompress and Raw
+	for name, opt := range map[string]moibit.ReadOption{"NoDecompress": moibit.NoDecompress(), "Raw": moibit.Raw()} {
+		stored, err := client.ReadFile("/logs/app.json", 0, opt)
+		if err != nil || !bytes.HasPrefix(stored, []byte("MBZ1")) || len(stored) != file.FileSize {
+			t.Errorf("ReadFile with %v = %v bytes, %v, want the %v bytes stored", name, len(stored), err, file.FileSize)
+		}
+	}
+
+	// Files without the header are read as they are
+	writeFiles(t, client, map[string]string{"/plain.txt": "plain", "/tiny.txt": "MBZ"})
+	for name, text := range map[string]string{"/plain.txt": "plain", "/tiny.txt": "MBZ"} {
+		if data, err := client.ReadFile(name, 0); err != nil || string(data) != text {
+			t.Errorf("ReadFile(%v) = %q, %v, want %q", name, data, err, text)
+		}
+	}
+}
+
+// forgedCompressed returns the given data compressed with the compression header and the given recorded size
+func forgedCompressed(t *testing.T, data []byte, size uint64) []byte {
+	t.Helper()
+
+	buffer := bytes.NewBufferString("MBZ1\x01")
+	writer := gzip.NewWriter(buffer)
+	writer.Header.Extra = binary.LittleEndian.AppendUint64([]byte{'M', 'S', 8, 0}, size)
+
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecompressForgedSize(t *testing.T) {
+	_, client := newTestClient(t)
+
+	data := logData(10000)
+	tests := []struct {
+		name string
+		size uint64
+	}{
+		{"/impossible.bin", 1 << 62},
+		{"/negative.bin", 1 << 63},
+		{"/larger.bin", uint64(len(data)) + 1},
+		{"/smaller.bin", uint64(len(data)) - 1},
+	}
+
+	for _, test := range tests {
+		if _, err := client.WriteFile(forgedCompressed(t, data, test.size), test.name, moibit.BinaryUpload()); err != nil {
+			t.Fatal(err)
+		}
+
+		// The recorded size is never trusted to allocate or to end the data
+		if read, err := client.ReadFile(test.name, 0); err == nil {
+			t.Errorf("ReadFile(%v) = %v bytes, want an error for the recorded size %v", test.name, len(read), test.size)
+		}
+	}
+
+	if _, err := client.FS("/").WithDecodedSizes().Stat("impossible.bin"); err == nil {
+		t.Error("Stat of a file with an impossible recorded size succeeded, want an error")
+	}
+}
+
+func TestCompressEncrypted(t *testing.T) {
+	_, client, _ := newEncryptedClient(t, staticKey(t, "k1", 1))
+
+	data := logData(200000)
+	if _, err := client.WriteFile(data, "/logs/app.json", moibit.Compress(moibit.GzipCompression)); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := client.ReadFile("/logs/app.json", 0)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("ReadFile = %v bytes, %v, want the written data", len(read), err)
+	}
+
+	// The compressed data is still decrypted with NoDecompress
+	if compressed, err := client.ReadFile("/logs/app.json", 0, moibit.NoDecompress()); err != nil || !bytes.HasPrefix(compressed, []byte("MBZ1")) {
+		t.Errorf("ReadFile with NoDecompress = %v bytes, %v, want the decrypted compressed data", len(compressed), err)
+	}
+}
+
+func TestCompressFS(t *testing.T) {
+	_, client := newTestClient(t)
+	gzip := moibit.Compress(moibit.GzipCompression)
+
+	data := logData(400000)
+	if _, err := client.WriteFile(data, "/logs/app.json", gzip); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.WriteFrom(context.Background(), bytes.NewReader(data), "/logs/stream.json", gzip); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, client, map[string]string{"/logs/plain.txt": "plain"})
+
+	fsys := client.FS("/").WithDecodedSizes()
+	if err := fstest.TestFS(fsys, "logs/app.json", "logs/stream.json", "logs/plain.txt"); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"logs/app.json", "logs/stream.json"} {
+		info, err := fsys.Stat(name)
+		if err != nil || info.Size() != int64(len(data)) {
+			t.Errorf("Stat(%v) = %v, want the size of the uncompressed data %v", name, err, len(data))
+		}
+	}
+
+	// By default, the FS reports the stored size of the files
+	if info, err := client.FS("/").Stat("logs/app.json"); err != nil || info.Size() >= int64(len(data)) {
+		t.Errorf("Stat(logs/app.json) = %v, want the size of the compressed data", err)
+	}
+
+	// With NoDecompress, the FS serves the files as they are stored
+	stored := client.FS("/").WithDecodedSizes().WithReadOptions(moibit.NoDecompress())
+	if err := fstest.TestFS(stored, "logs/app.json", "logs/stream.json", "logs/plain.txt"); err != nil {
+		t.Error(err)
+	}
+
+	if info, err := stored.Stat("logs/app.json"); err != nil || info.Size() >= int64(len(data)) {
+		t.Errorf("Stat(logs/app.json) with NoDecompress = %v, want the size of the compressed data", err)
+	}
+}
@@ -0,0 +1,444 @@
+package moibit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// KeyProvider wraps and unwraps the data keys of files encrypted on the client.
+// Every file is encrypted with its own random data key, which is wrapped (encrypted) by the
+// KeyProvider and stored in the header of the file along with the ID of the wrapping key.
+// Implementations can be backed by local keys, keyrings or an external key management service.
+type KeyProvider interface {
+	// KeyID returns the ID of the key that wraps new data keys
+	KeyID() string
+
+	// WrapKey encrypts the given data key with the key identified by KeyID
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+
+	// UnwrapKey decrypts the given wrapped data key with the key of the given ID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// ClientEncryption returns a ClientOption that can be used to encrypt files on the client before they are written
+// to MOIBit, so that their plaintext never leaves the process. Every file is encrypted with AES-256-GCM with its own
+// data key, which is wrapped by the given KeyProvider and stored in a small header before the ciphertext.
+// Files are decrypted transparently when they are read, and reading a file without an encryption header fails with an
+// error matching ErrIntegrity, since anyone with write access to the app could have replaced it. Files that are not
+// encrypted on the client can only be read with the Raw option.
+//
+// Note: Hashes and sizes reported by MOIBit describe the encrypted data, so the Verify option
+// checks the ciphertext and Sync compares encrypted files by their modification time only.
+func ClientEncryption(provider KeyProvider) ClientOption {
+	return func(client *Client) error {
+		if provider == nil {
+			return fmt.Errorf("nil key provider")
+		}
+
+		client.keys = provider
+		return nil
+	}
+}
+
+// staticKey is a KeyProvider that wraps data keys with a single AES-256 key
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+// StaticKey returns a KeyProvider that wraps data keys with the given 32 byte key with AES-256-GCM.
+// The ID identifies the key in the header of encrypted files and must not be empty.
+func StaticKey(id string, key []byte) (KeyProvider, error) {
+	if id == "" {
+		return nil, fmt.Errorf("empty key id")
+	}
+
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid key size: %v bytes, expected %v", len(key), dataKeySize)
+	}
+
+	return &staticKey{id: id, key: append([]byte(nil), key...)}, nil
+}
+
+// KeyID implements the KeyProvider interface for staticKey
+func (provider *staticKey) KeyID() string {
+	return provider.id
+}
+
+// WrapKey implements the KeyProvider interface for staticKey
+func (provider *staticKey) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	return sealKey(provider.key, provider.id, dataKey)
+}
+
+// UnwrapKey implements the KeyProvider interface for staticKey
+func (provider *staticKey) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != provider.id {
+		return nil, fmt.Errorf("unknown key id '%v'", keyID)
+	}
+
+	return openKey(provider.key, provider.id, wrapped)
+}
+
+// sealKey wraps the given data key with the given key encryption key with AES-256-GCM.
+// The wrapped key is the random nonce followed by the sealed data key, authenticated with the key ID.
+func sealKey(kek []byte, keyID string, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+// openKey unwraps the given data key wrapped by sealKey with the given key encryption key
+func openKey(kek []byte, keyID string, wrapped []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("data key could not be unwrapped: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// newAEAD returns an AES-GCM cipher for the given key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+const (
+	// dataKeySize is the size of the data keys and static keys (AES-256)
+	dataKeySize = 32
+
+	// segmentSize is the size of the plaintext segments that are sealed individually
+	segmentSize = 64 << 10
+
+	// maxSegmentSize is the largest segment size accepted in the header of an encrypted file
+	maxSegmentSize = 16 << 20
+
+	// algorithmAESGCM identifies the AES-256-GCM segmented encryption in the header
+	algorithmAESGCM = 1
+)
+
+// encryptionMagic is the magic number at the start of the header of encrypted files
+var encryptionMagic = []byte("MBE1")
+
+// envelopeHeader is the header of a file encrypted on the client. It is encoded as:
+// magic (4) | algorithm (1) | segment size (4) | nonce prefix (7) | key id length (2) | key id | wrapped key length (2) | wrapped key
+type envelopeHeader struct {
+	Algorithm   byte
+	SegmentSize uint32
+	NoncePrefix [7]byte
+	KeyID       string
+	WrappedKey  []byte
+}
+
+// marshal returns the binary encoding of the envelopeHeader
+func (header *envelopeHeader) marshal() []byte {
+	buffer := append([]byte(nil), encryptionMagic...)
+	buffer = append(buffer, header.Algorithm)
+	buffer = binary.BigEndian.AppendUint32(buffer, header.SegmentSize)
+	buffer = append(buffer, header.NoncePrefix[:]...)
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(header.KeyID)))
+	buffer = append(buffer, header.KeyID...)
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(header.WrappedKey)))
+	return append(buffer, header.WrappedKey...)
+}
+
+// readEnvelopeHeader decodes an envelopeHeader from the given reader, which must start with the magic number
+func readEnvelopeHeader(r io.Reader) (*envelopeHeader, error) {
+	fixed := make([]byte, len(encryptionMagic)+1+4+7)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("encryption header: %w", err)
+	}
+
+	if !bytes.Equal(fixed[:len(encryptionMagic)], encryptionMagic) {
+		return nil, fmt.Errorf("encryption header: bad magic number")
+	}
+
+	header := &envelopeHeader{Algorithm: fixed[4], SegmentSize: binary.BigEndian.Uint32(fixed[5:9])}
+	copy(header.NoncePrefix[:], fixed[9:])
+
+	if header.Algorithm != algorithmAESGCM {
+		return nil, fmt.Errorf("encryption header: unsupported algorithm %v", header.Algorithm)
+	}
+
+	if header.SegmentSize == 0 || header.SegmentSize > maxSegmentSize {
+		return nil, fmt.Errorf("encryption header: invalid segment size %v", header.SegmentSize)
+	}
+
+	keyID, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, fmt.Errorf("encryption header: %w", err)
+	}
+
+	wrapped, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, fmt.Errorf("encryption header: %w", err)
+	}
+
+	header.KeyID, header.WrappedKey = string(keyID), wrapped
+	return header, nil
+}
+
+// readLengthPrefixed reads a field prefixed by its 16-bit length from the given reader
+func readLengthPrefixed(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	field := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// size returns the size of the plaintext for the given size of the encrypted file with the envelopeHeader.
+// Returns -1 if the size of the encrypted file is unknown or invalid.
+func (header *envelopeHeader) size(encrypted int64) int64 {
+	ciphertext := encrypted - int64(len(header.marshal()))
+	if encrypted < 0 || ciphertext < gcmTagSize {
+		return -1
+	}
+
+	sealed := int64(header.SegmentSize) + gcmTagSize
+	segments := (ciphertext + sealed - 1) / sealed
+	return ciphertext - segments*gcmTagSize
+}
+
+// gcmTagSize is the size of the authentication tag of every sealed segment
+const gcmTagSize = 16
+
+// segmentNonce returns the nonce of the segment with the given index.
+// The nonce is the prefix, the big endian index and a flag marking the last segment.
+func segmentNonce(prefix [7]byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix[:])
+	binary.BigEndian.PutUint32(nonce[7:], index)
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// encrypt returns a reader of the given plaintext encrypted with a new data key wrapped by the KeyProvider of the client
+func (client *Client) encrypt(ctx context.Context, plaintext io.Reader) (io.Reader, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("data key generation failed: %w", err)
+	}
+
+	wrapped, err := client.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key could not be wrapped: %w", err)
+	}
+
+	header := &envelopeHeader{Algorithm: algorithmAESGCM, SegmentSize: segmentSize, KeyID: client.keys.KeyID(), WrappedKey: wrapped}
+	if _, err := rand.Read(header.NoncePrefix[:]); err != nil {
+		return nil, fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptReader{
+		src: bufio.NewReader(plaintext), aead: aead, header: header,
+		pending: header.marshal(), plain: make([]byte, segmentSize),
+	}, nil
+}
+
+// decrypt returns a reader of the plaintext of the given encrypted file data, which must start with the envelopeHeader.
+// The data key is unwrapped with the KeyProvider of the client.
+func (client *Client) decrypt(ctx context.Context, encrypted io.Reader) (io.Reader, *envelopeHeader, error) {
+	header, err := readEnvelopeHeader(encrypted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := client.keys.UnwrapKey(ctx, header.KeyID, header.WrappedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("data key could not be unwrapped with key '%v': %w", header.KeyID, err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &decryptReader{
+		src: bufio.NewReader(encrypted), aead: aead, header: header,
+		sealed: make([]byte, int(header.SegmentSize)+gcmTagSize),
+	}, header, nil
+}
+
+// isEncrypted returns whether the given reader starts with the magic number of an encrypted file
+func isEncrypted(r *bufio.Reader) bool {
+	magic, _ := r.Peek(len(encryptionMagic))
+	return bytes.Equal(magic, encryptionMagic)
+}
+
+// encryptReader is an io.Reader of the header and sealed segments of the plaintext read from the source
+type encryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header *envelopeHeader
+
+	index   uint32
+	plain   []byte
+	pending []byte
+	done    bool
+}
+
+// Read implements the io.Reader interface for encryptReader
+func (reader *encryptReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		// Read the next segment and check whether any data remains after it
+		n, err := io.ReadFull(reader.src, reader.plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		if err == nil {
+			if _, err = reader.src.Peek(1); err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+
+		last := err != nil
+		nonce := segmentNonce(reader.header.NoncePrefix, reader.index, last)
+		reader.pending = reader.aead.Seal(reader.pending[:0], nonce, reader.plain[:n], nil)
+		reader.index++
+		reader.done = last
+	}
+
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
+
+// decryptReader is an io.Reader of the plaintext of the sealed segments read from the source
+type decryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header *envelopeHeader
+
+	index   uint32
+	sealed  []byte
+	pending []byte
+	done    bool
+}
+
+// Read implements the io.Reader interface for decryptReader.
+// Returns an error matching ErrIntegrity if a segment fails authentication or the data is truncated.
+func (reader *decryptReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		// Read the next sealed segment and check whether any data remains after it
+		n, err := io.ReadFull(reader.src, reader.sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		if err == nil {
+			if _, err = reader.src.Peek(1); err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+
+		last := err != nil
+		nonce := segmentNonce(reader.header.NoncePrefix, reader.index, last)
+
+		plain, err := reader.aead.Open(reader.sealed[:0], nonce, reader.sealed[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("segment %v could not be authenticated: %w", reader.index, ErrIntegrity)
+		}
+
+		reader.pending = plain
+		reader.index++
+		reader.done = last
+	}
+
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
+
+// Raw returns a ReadOption that can be used to read the data of a file as it is stored on MOIBit, without decrypting
+// or decompressing it. With ClientEncryption, it is required to read files that were not encrypted on the client.
+func Raw() ReadOption {
+	return func(request *requestReadFile) error {
+		request.raw = true
+		return nil
+	}
+}
+
+// rawWrite returns a WriteOption that writes the data of a file as it is given, without encrypting it
+func rawWrite() WriteOption {
+	return func(request *requestWriteFile) error {
+		request.raw = true
+		return nil
+	}
+}
+
+// decryptFile replaces the data of the given FileReader with its plaintext.
+// The Size of the FileReader is updated to the size of the plaintext.
+// Returns an error matching ErrIntegrity if the data does not start with an encryption header.
+func (client *Client) decryptFile(ctx context.Context, file *FileReader) error {
+	buffered := bufio.NewReader(file.ReadCloser)
+	if !isEncrypted(buffered) {
+		return fmt.Errorf("file is not encrypted: %w", ErrIntegrity)
+	}
+
+	plaintext, header, err := client.decrypt(ctx, buffered)
+	if err != nil {
+		return err
+	}
+
+	file.ReadCloser = readCloser{plaintext, file.ReadCloser}
+	file.Size = header.size(file.Size)
+	return nil
+}
+
+// readCloser combines an io.Reader with the io.Closer of the stream it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
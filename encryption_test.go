@@ -0,0 +1,241 @@
+package moibit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/fstest"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// segment is the size of the plaintext segments of encrypted files
+const segment = 64 << 10
+
+// staticKey returns a KeyProvider for the given key ID with a key derived from the given seed
+func staticKey(t *testing.T, id string, seed int64) moibit.KeyProvider {
+	t.Helper()
+
+	key := make([]byte, 32)
+	rand.New(rand.NewSource(seed)).Read(key)
+
+	provider, err := moibit.StaticKey(id, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+// newEncryptedClient starts a moibittest.Server and returns it with a Client that encrypts files with the given
+// KeyProvider, and another Client for the same app that reads and writes the stored data as it is
+func newEncryptedClient(t *testing.T, provider moibit.KeyProvider) (*moibittest.Server, *moibit.Client, *moibit.Client) {
+	t.Helper()
+
+	server, client := newTestClient(t, moibit.ClientEncryption(provider))
+
+	plain, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, client, plain
+}
+
+// randomData returns pseudo random data of the given size
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	_, client, plain := newEncryptedClient(t, staticKey(t, "k1", 1))
+
+	for _, size := range []int{0, 1, segment - 1, segment, segment + 1, 3*segment + 100} {
+		data := randomData(size)
+		if _, err := client.WriteFile(data, "/file.bin"); err != nil {
+			t.Fatalf("WriteFile(%v bytes) failed: %v", size, err)
+		}
+
+		read, err := client.ReadFile("/file.bin", 0)
+		if err != nil || !bytes.Equal(read, data) {
+			t.Errorf("ReadFile(%v bytes) = %v bytes, %v, want the written data", size, len(read), err)
+		}
+
+		file, err := client.OpenFile("/file.bin", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Close()
+		if file.Size != int64(size) {
+			t.Errorf("OpenFile(%v bytes).Size = %v, want the size of the plaintext", size, file.Size)
+		}
+
+		// The stored data is the header followed by the sealed segments
+		stored, err := plain.ReadFile("/file.bin", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Short plaintexts may occur in the ciphertext by chance
+		if !bytes.HasPrefix(stored, []byte("MBE1")) || (size >= 16 && bytes.Contains(stored, data)) {
+			t.Errorf("stored data of %v bytes is not encrypted", size)
+		}
+
+		// The same data can be read with the Raw option
+		raw, err := client.ReadFile("/file.bin", 0, moibit.Raw())
+		if err != nil || !bytes.Equal(raw, stored) {
+			t.Errorf("ReadFile with Raw = %v, want the stored data", err)
+		}
+	}
+}
+
+func TestEncryptionStream(t *testing.T) {
+	_, client, _ := newEncryptedClient(t, staticKey(t, "k1", 1))
+
+	data := randomData(2*segment + 7)
+	if _, err := client.WriteFrom(context.Background(), bytes.NewReader(data), "/stream.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if _, err := client.ReadFileTo(&buffer, "/stream.bin", 0); err != nil || !bytes.Equal(buffer.Bytes(), data) {
+		t.Errorf("ReadFileTo = %v bytes, %v, want the written data", buffer.Len(), err)
+	}
+}
+
+func TestEncryptionTampered(t *testing.T) {
+	_, client, plain := newEncryptedClient(t, staticKey(t, "k1", 1))
+
+	data := randomData(3*segment + 100)
+	if _, err := client.WriteFile(data, "/file.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := plain.ReadFile("/file.bin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The header is followed by 4 segments sealed with a tag each
+	header := len(stored) - len(data) - 4*16
+	sealed := segment + 16
+
+	// modify returns a copy of the stored data modified by the given function
+	modify := func(fn func(data []byte) []byte) []byte {
+		return fn(append([]byte(nil), stored...))
+	}
+
+	// flip returns a copy of the stored data with the byte at the given offset flipped
+	flip := func(offset int) []byte {
+		return modify(func(data []byte) []byte {
+			data[offset] ^= 0x01
+			return data
+		})
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		integrity bool
+	}{
+		{"plaintext", data, true},
+		{"empty", []byte{}, true},
+		{"magic number", flip(0), true},
+		{"nonce prefix", flip(9), true},
+		{"key id", flip(18), false},
+		{"wrapped key", flip(header - 1), false},
+		{"ciphertext", flip(header + sealed + 10), true},
+		{"tag", flip(len(stored) - 1), true},
+		{"last segment dropped", stored[:header+3*sealed], true},
+		{"truncated segment", stored[:len(stored)-10], true},
+		{"header only", stored[:header], true},
+		{"appended data", append(append([]byte(nil), stored...), 0), true},
+		{"segments swapped", modify(func(data []byte) []byte {
+			first, second := data[header:header+sealed], data[header+sealed:header+2*sealed]
+			swapped := append(append([]byte(nil), second...), first...)
+			copy(data[header:], swapped)
+			return data
+		}), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := plain.WriteFile(test.data, "/file.bin"); err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := client.ReadFile("/file.bin", 0)
+			if err == nil {
+				t.Fatalf("ReadFile of a tampered file = %v bytes, want an error", len(read))
+			}
+
+			if integrity := errors.Is(err, moibit.ErrIntegrity); integrity != test.integrity {
+				t.Errorf("ReadFile of a tampered file = %v, matching ErrIntegrity: %v, want %v", err, integrity, test.integrity)
+			}
+		})
+	}
+}
+
+func TestEncryptionRaw(t *testing.T) {
+	_, client, plain := newEncryptedClient(t, staticKey(t, "k1", 1))
+	writeFiles(t, plain, map[string]string{"/plain.txt": "not encrypted"})
+
+	if _, err := client.ReadFile("/plain.txt", 0); !errors.Is(err, moibit.ErrIntegrity) {
+		t.Errorf("ReadFile of a file that is not encrypted = %v, want %v", err, moibit.ErrIntegrity)
+	}
+
+	if data, err := client.ReadFile("/plain.txt", 0, moibit.Raw()); err != nil || string(data) != "not encrypted" {
+		t.Errorf("ReadFile with Raw = %q, %v, want the stored data", data, err)
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	server, client, _ := newEncryptedClient(t, staticKey(t, "k1", 1))
+	writeFiles(t, client, map[string]string{"/secret.txt": "secret"})
+
+	for name, provider := range map[string]moibit.KeyProvider{
+		"different key": staticKey(t, "k1", 2),
+		"different id":  staticKey(t, "k2", 1),
+	} {
+		other, err := server.NewClient(moibit.ClientEncryption(provider))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data, err := other.ReadFile("/secret.txt", 0); err == nil {
+			t.Errorf("ReadFile with a %v = %q, want an error", name, data)
+		}
+	}
+}
+
+func TestEncryptionFS(t *testing.T) {
+	_, client, _ := newEncryptedClient(t, staticKey(t, "k1", 1))
+	writeFiles(t, client, map[string]string{"/e.txt": "encrypted text", "/dir/empty.txt": ""})
+
+	if _, err := client.WriteFile(randomData(segment+1), "/dir/large.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(client.FS("/"), "e.txt", "dir/empty.txt", "dir/large.bin"); err != nil {
+		t.Error(err)
+	}
+
+	file, err := client.FS("/").Open("dir/large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	end, err := file.(io.Seeker).Seek(0, io.SeekEnd)
+	if info, _ := file.Stat(); err != nil || end != segment+1 || info.Size() != segment+1 {
+		t.Errorf("size of the plaintext = %v (Seek), %v, want %v", end, err, segment+1)
+	}
+}
@@ -0,0 +1,148 @@
+package moibit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is matched by an APIError when the requested file, directory or resource does not exist
+	ErrNotFound = errors.New("moibit: not found")
+
+	// ErrUnauthorized is matched by an APIError when the credentials of the client are invalid or expired
+	ErrUnauthorized = errors.New("moibit: unauthorized")
+
+	// ErrAlreadyExists is matched by an APIError when the file or directory being created already exists
+	ErrAlreadyExists = errors.New("moibit: already exists")
+
+	// ErrQuotaExceeded is matched by an APIError when the storage or plan limits of the developer are exhausted
+	ErrQuotaExceeded = errors.New("moibit: quota exceeded")
+
+	// ErrRateLimited is matched by an APIError when MOIBit throttles the requests from the client
+	ErrRateLimited = errors.New("moibit: rate limited")
+
+	// ErrIntegrity is matched by an IntegrityError when the data read from MOIBit does not match its hash or size
+	ErrIntegrity = errors.New("moibit: integrity check failed")
+)
+
+// APIError represents a non-ok response returned by the MOIBit API.
+// It can be inspected with errors.As and compared against the sentinel
+// errors (ErrNotFound, ErrUnauthorized, etc.) with errors.Is.
+type APIError struct {
+	// HTTP Status Code of the response
+	StatusCode int
+	// Status Code from the metadata of the response
+	Code int
+	// Metadata message returned with the response
+	Message string
+	// Additional error data returned by some endpoints
+	Detail string
+	// Request ID of the corresponding response
+	RequestID string
+	// API endpoint that returned the response
+	Endpoint string
+	// Err is the error that prevented the response from being decoded, if any
+	Err error
+}
+
+// newAPIError generates an APIError for a response from the given
+// endpoint with the given HTTP status code and response metadata.
+func newAPIError(endpoint string, status int, meta responseMetadata) *APIError {
+	return &APIError{
+		StatusCode: status,
+		Code:       meta.StatusCode,
+		Message:    meta.Message,
+		RequestID:  meta.RequestID,
+		Endpoint:   endpoint,
+	}
+}
+
+// newDecodeError generates an APIError for a response from the given endpoint
+// with the given HTTP status code that could not be decoded.
+func newDecodeError(endpoint string, status int, err error) *APIError {
+	return &APIError{StatusCode: status, Endpoint: endpoint, Err: err}
+}
+
+// Error implements the error interface for APIError
+func (err *APIError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("response decode failed from %v [HTTP %v]: %v", err.Endpoint, err.StatusCode, err.Err)
+	}
+
+	message := fmt.Sprintf("non-ok response from %v [%v]", err.Endpoint, err.code())
+	if err.Message != "" {
+		message = fmt.Sprintf("%v: %v", message, err.Message)
+	}
+
+	if err.Detail != "" {
+		message = fmt.Sprintf("%v | %v", message, err.Detail)
+	}
+
+	if err.RequestID != "" {
+		message = fmt.Sprintf("%v (request %v)", message, err.RequestID)
+	}
+
+	return message
+}
+
+// Unwrap returns the error that prevented the response from being decoded, if any
+func (err *APIError) Unwrap() error {
+	return err.Err
+}
+
+// Is reports whether the APIError matches the given target error.
+// The sentinel errors are matched based on the status code of the error.
+func (err *APIError) Is(target error) bool {
+	switch code := err.code(); target {
+	case ErrNotFound:
+		return code == http.StatusNotFound
+	case ErrUnauthorized:
+		return code == http.StatusUnauthorized || code == http.StatusForbidden
+	case ErrAlreadyExists:
+		return code == http.StatusConflict
+	case ErrQuotaExceeded:
+		return code == http.StatusPaymentRequired || code == http.StatusInsufficientStorage
+	case ErrRateLimited:
+		return code == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+// code returns the effective status code of the APIError.
+// The metadata status code is preferred over the HTTP status code if it is set.
+func (err *APIError) code() int {
+	if err.Code != 0 {
+		return err.Code
+	}
+
+	return err.StatusCode
+}
+
+// IntegrityError represents a mismatch between the data of a file read from MOIBit with
+// the Verify option and the hash and size that MOIBit reports for the file version.
+// It can be compared against ErrIntegrity with errors.Is.
+type IntegrityError struct {
+	// Path of the file that was read
+	Path string
+	// Version of the file that was read
+	Version int
+	// Hash and size reported by MOIBit for the file version
+	ExpectedHash string
+	ExpectedSize int64
+	// Hash and size computed from the data that was read
+	ActualHash string
+	ActualSize int64
+}
+
+// Error implements the error interface for IntegrityError
+func (err *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed for %v (version %v): expected %v (%v bytes), read %v (%v bytes)",
+		err.Path, err.Version, err.ExpectedHash, err.ExpectedSize, err.ActualHash, err.ActualSize)
+}
+
+// Is reports whether the IntegrityError matches the given target error
+func (err *IntegrityError) Is(target error) bool {
+	return target == ErrIntegrity
+}
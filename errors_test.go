@@ -0,0 +1,84 @@
+package moibit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrUnauthorized, ErrAlreadyExists, ErrQuotaExceeded, ErrRateLimited, ErrIntegrity}
+
+	tests := []struct {
+		name    string
+		status  int
+		code    int
+		matches error
+	}{
+		{"not found", http.StatusNotFound, 0, ErrNotFound},
+		{"unauthorized", http.StatusUnauthorized, 0, ErrUnauthorized},
+		{"forbidden", http.StatusForbidden, 0, ErrUnauthorized},
+		{"conflict", http.StatusConflict, 0, ErrAlreadyExists},
+		{"payment required", http.StatusPaymentRequired, 0, ErrQuotaExceeded},
+		{"insufficient storage", http.StatusInsufficientStorage, 0, ErrQuotaExceeded},
+		{"too many requests", http.StatusTooManyRequests, 0, ErrRateLimited},
+		{"internal error", http.StatusInternalServerError, 0, nil},
+		{"meta code overrides ok status", http.StatusOK, http.StatusNotFound, ErrNotFound},
+		{"meta code overrides error status", http.StatusInternalServerError, http.StatusConflict, ErrAlreadyExists},
+		{"meta code overrides sentinel status", http.StatusNotFound, http.StatusTooManyRequests, ErrRateLimited},
+		{"meta code without sentinel", http.StatusNotFound, http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := fmt.Errorf("request failed: %w", newAPIError("/listfiles", test.status, responseMetadata{StatusCode: test.code}))
+
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(err, sentinel), sentinel == test.matches; got != want {
+					t.Errorf("errors.Is(%v) = %v, want %v", sentinel, got, want)
+				}
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status || apiErr.Endpoint != "/listfiles" {
+				t.Errorf("errors.As = %+v, want the APIError of the response", apiErr)
+			}
+		})
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	err := newAPIError("/readfile", http.StatusOK, responseMetadata{StatusCode: http.StatusNotFound, RequestID: "req-1", Message: "file not found"})
+	err.Detail = "no such version"
+
+	want := "non-ok response from /readfile [404]: file not found | no such version (request req-1)"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestIntegrityErrorIs(t *testing.T) {
+	err := fmt.Errorf("read failed: %w", &IntegrityError{Path: "/a.txt", ExpectedHash: "Qm1", ActualHash: "Qm2"})
+	if !errors.Is(err, ErrIntegrity) {
+		t.Error("IntegrityError does not match ErrIntegrity")
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		t.Error("IntegrityError matches ErrNotFound")
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := fmt.Errorf("request failed: %w", newDecodeError("/listfiles", http.StatusNotFound, cause))
+
+	if !errors.Is(err, cause) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("decode error %v does not match its cause and its status", err)
+	}
+
+	want := "request failed: response decode failed from /listfiles [HTTP 404]: unexpected EOF"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
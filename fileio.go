@@ -2,29 +2,136 @@ package moibit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"unicode/utf8"
 )
 
 // requestReadFile is the request for the ReadFile API of MOIBit
 type requestReadFile struct {
 	FileName string `json:"fileName"`
 	Version  int    `json:"version"`
+
+	verify       bool
+	raw          bool
+	noDecompress bool
+}
+
+// responseReadFile is the response for the ReadFile API of MOIBit when it fails.
+// A successful response contains the raw file data instead.
+type responseReadFile struct {
+	Metadata responseMetadata `json:"meta"`
+}
+
+// ReadOption is a request option for the ReadFile, OpenFile and ReadFileTo methods of Client.
+type ReadOption func(*requestReadFile) error
+
+// Verify returns a ReadOption that can be used to verify the integrity of the file data that is read.
+// The hash and size of the requested version are resolved with FileStatus (or FileVersions for a
+// specific version) before the read, and the data is hashed as it is streamed. A mismatch is reported
+// with an IntegrityError, which matches ErrIntegrity, once the end of the data is reached.
+// Note: Data streamed with OpenFile or ReadFileTo has already been consumed when a mismatch is reported.
+func Verify() ReadOption {
+	return func(request *requestReadFile) error {
+		request.verify = true
+		return nil
+	}
 }
 
 // ReadFile reads a file from MOIBit at the given path for the given version.
+// It also accepts a variadic number of ReadOption to modify the read request.
 // Returns the []byte data of the file and an error.
-func (client *Client) ReadFile(path string, version int) ([]byte, error) {
+func (client *Client) ReadFile(path string, version int, opts ...ReadOption) ([]byte, error) {
+	return client.ReadFileContext(context.Background(), path, version, opts...)
+}
+
+// ReadFileContext reads a file from MOIBit at the given path for the given version.
+// It behaves like ReadFile but carries the given context on the outgoing request.
+func (client *Client) ReadFileContext(ctx context.Context, path string, version int, opts ...ReadOption) ([]byte, error) {
+	// Open the file for streaming
+	file, err := client.OpenFileContext(ctx, path, version, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// Preallocate the buffer if the size of the file is known, the size is
+	// only a hint and the buffer still grows if more data is read
+	buffer := new(bytes.Buffer)
+	if file.Size > 0 {
+		buffer.Grow(int(min64(file.Size, maxPreallocSize)))
+	}
+
+	// Read all bytes from the response body
+	if _, err := buffer.ReadFrom(file); err != nil {
+		return nil, fmt.Errorf("response data spool: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// maxPreallocSize is the maximum size of the buffer that ReadFile preallocates for the data of a file
+const maxPreallocSize = 64 << 20
+
+// min64 returns the smaller of the given integers
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// FileReader is a stream of the data of a file on MOIBit, returned by OpenFile.
+// The data is read directly from the response body, which is released when the FileReader is closed.
+type FileReader struct {
+	io.ReadCloser
+
+	// Size is the size of the file data in bytes as reported by the
+	// Content-Length of the response. It is -1 if the size is unknown.
+	Size int64
+}
+
+// OpenFile opens a file on MOIBit at the given path for the given version for streaming.
+// It also accepts a variadic number of ReadOption to modify the read request.
+// Returns a FileReader that streams the data of the file, which must be closed by the caller.
+func (client *Client) OpenFile(path string, version int, opts ...ReadOption) (*FileReader, error) {
+	return client.OpenFileContext(context.Background(), path, version, opts...)
+}
+
+// OpenFileContext opens a file on MOIBit at the given path for the given version for streaming.
+// It behaves like OpenFile but carries the given context on the outgoing request.
+// The context must remain active until the data of the FileReader has been read.
+func (client *Client) OpenFileContext(ctx context.Context, path string, version int, opts ...ReadOption) (*FileReader, error) {
 	// Generate Request Data
-	requestData, err := json.Marshal(requestReadFile{path, version})
+	request := &requestReadFile{FileName: path, Version: version}
+	for _, opt := range opts {
+		if err := opt(request); err != nil {
+			return nil, fmt.Errorf("request creation failed while applying options: %w", err)
+		}
+	}
+
+	// Resolve the expected hash and size of the file version to verify
+	var expected FileVersionDescriptor
+	if request.verify {
+		var err error
+		if expected, err = client.expectedVersion(ctx, path, version); err != nil {
+			return nil, fmt.Errorf("file version could not be resolved for verification: %w", err)
+		}
+	}
+
+	// Serialize Request Data
+	requestData, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("request serialization failed: %w", err)
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/readfile"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/readfile"), bytes.NewReader(requestData))
 	if err != nil {
 		return nil, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -33,23 +140,73 @@ func (client *Client) ReadFile(path string, version int) ([]byte, error) {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
 	// Check the status code of response
 	if responseHTTP.StatusCode != 200 {
-		return nil, fmt.Errorf("non-ok response [%v]", responseHTTP.StatusCode)
+		defer responseHTTP.Body.Close()
+
+		// Attempt to decode the error metadata from the response
+		response := new(responseReadFile)
+		_ = json.NewDecoder(responseHTTP.Body).Decode(response)
+
+		return nil, newAPIError("/readfile", responseHTTP.StatusCode, response.Metadata)
 	}
 
-	// Read all bytes from the response body
-	data, err := io.ReadAll(responseHTTP.Body)
+	// Hand over the response body to the caller, verifying it as it is read if required
+	file := &FileReader{ReadCloser: responseHTTP.Body, Size: responseHTTP.ContentLength}
+	if request.verify {
+		file.ReadCloser = newVerifyingReader(responseHTTP.Body, path, expected)
+	}
+
+	// Decrypt the file data if it was encrypted on the client
+	if client.keys != nil && !request.raw {
+		if err := client.decryptFile(ctx, file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("file decryption failed: %w", err)
+		}
+	}
+
+	// Decompress the file data if it was compressed on the client
+	if !request.noDecompress && !request.raw {
+		if err := decompressFile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("file decompression failed: %w", err)
+		}
+	}
+
+	return file, nil
+}
+
+// ReadFileTo reads a file from MOIBit at the given path for the given version into the given io.Writer.
+// The data of the file is streamed into the writer without being buffered in memory.
+// It also accepts a variadic number of ReadOption to modify the read request.
+// Returns the number of bytes written and an error.
+func (client *Client) ReadFileTo(w io.Writer, path string, version int, opts ...ReadOption) (int64, error) {
+	return client.ReadFileToContext(context.Background(), w, path, version, opts...)
+}
+
+// ReadFileToContext reads a file from MOIBit at the given path for the given version into the given io.Writer.
+// It behaves like ReadFileTo but carries the given context on the outgoing request.
+func (client *Client) ReadFileToContext(ctx context.Context, w io.Writer, path string, version int, opts ...ReadOption) (int64, error) {
+	// Open the file for streaming
+	file, err := client.OpenFileContext(ctx, path, version, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("response data spool: %w", err)
+		return 0, err
 	}
 
-	return data, nil
+	defer file.Close()
+
+	// Stream the file data into the writer
+	written, err := io.Copy(w, file)
+	if err != nil {
+		return written, fmt.Errorf("response data stream: %w", err)
+	}
+
+	return written, nil
 }
 
 // requestWriteFile is the request for the WriteFile API of MOIBit
@@ -63,12 +220,18 @@ type requestWriteFile struct {
 
 	Replication int            `json:"replication,omitempty"`
 	Encryption  EncryptionType `json:"encryptionType,omitempty"`
+
+	retry       bool
+	binary      bool
+	raw         bool
+	compression CompressionType
+	progress    func(sent int64)
 }
 
-// defaultWriteFileRequest generates a new requestWriteFile object for the given file name and data
-func defaultWriteFileRequest(data []byte, name string) *requestWriteFile {
+// defaultWriteFileRequest generates a new requestWriteFile object for the given file name
+func defaultWriteFileRequest(name string) *requestWriteFile {
 	return &requestWriteFile{
-		FileName: name, FileText: string(data),
+		FileName:     name,
 		KeepPrevious: false, CreateFolders: true, IsProvenance: false,
 	}
 }
@@ -81,24 +244,63 @@ type responseWriteFile struct {
 
 // WriteFile writes a given file to MOIBit. Accepts the file data as raw bytes and the file name.
 // It also accepts a variadic number of WriteOption to modify the write request.
+// Data that is not valid UTF-8 text is uploaded as binary with a multipart form (see BinaryUpload).
 // Returns a FileDescriptor (and error) containing the status of the file after successful write.
 func (client *Client) WriteFile(data []byte, name string, opts ...WriteOption) (FileDescriptor, error) {
+	return client.WriteFileContext(context.Background(), data, name, opts...)
+}
+
+// WriteFileContext writes a given file to MOIBit.
+// It behaves like WriteFile but carries the given context on the outgoing request.
+func (client *Client) WriteFileContext(ctx context.Context, data []byte, name string, opts ...WriteOption) (FileDescriptor, error) {
 	// Generate Request Data
-	request := defaultWriteFileRequest(data, name)
+	request := defaultWriteFileRequest(name)
 	for _, opt := range opts {
 		if err := opt(request); err != nil {
 			return FileDescriptor{}, fmt.Errorf("request creation failed while applying options: %w", err)
 		}
 	}
 
+	// Compress the file data on the client if required, the compressed data is always binary
+	if request.compression != NoCompression && !request.raw {
+		compressed, ok, err := compressData(data, request)
+		if err != nil {
+			return FileDescriptor{}, fmt.Errorf("file compression failed: %w", err)
+		}
+
+		if ok {
+			data, request.binary = compressed, true
+		}
+	}
+
+	// Encrypt the file data on the client if required, the ciphertext is always binary
+	if client.keys != nil && !request.raw {
+		encrypted, err := client.encrypt(ctx, bytes.NewReader(data))
+		if err != nil {
+			return FileDescriptor{}, fmt.Errorf("file encryption failed: %w", err)
+		}
+
+		if data, err = io.ReadAll(encrypted); err != nil {
+			return FileDescriptor{}, fmt.Errorf("file encryption failed: %w", err)
+		}
+
+		request.binary = true
+	}
+
+	// JSON text can only carry valid UTF-8, upload anything else as binary
+	if request.binary || !utf8.Valid(data) {
+		return client.uploadFile(ctx, data, request)
+	}
+
 	// Serialize Request Data
+	request.FileText = string(data)
 	requestData, err := json.Marshal(request)
 	if err != nil {
 		return FileDescriptor{}, fmt.Errorf("request serialization failed: %w", err)
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/writetexttofile"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/writetexttofile"), bytes.NewReader(requestData))
 	if err != nil {
 		return FileDescriptor{}, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -107,21 +309,29 @@ func (client *Client) WriteFile(data []byte, name string, opts ...WriteOption) (
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, request.retry)
 	if err != nil {
 		return FileDescriptor{}, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
+	return decodeWriteFile("/writetexttofile", responseHTTP)
+}
+
+// decodeWriteFile decodes the response of a write request to the given endpoint.
+// Returns the FileDescriptor of the written file or an error if the response is not ok.
+func decodeWriteFile(endpoint string, responseHTTP *http.Response) (FileDescriptor, error) {
 	// Decode the response into a responseWriteFiles
 	response := new(responseWriteFile)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return FileDescriptor{}, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return FileDescriptor{}, newDecodeError(endpoint, responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return FileDescriptor{}, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return FileDescriptor{}, newAPIError(endpoint, responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file descriptors from the response
@@ -131,14 +341,15 @@ func (client *Client) WriteFile(data []byte, name string, opts ...WriteOption) (
 // UnmarshalJSON implements the json.Unmarshaler interface for responseWriteFile.
 // The custom unmarshaler is required because of a bug in the WriteTextToFile
 // API causing to return the file descriptors in a deformed string form.
+// Well-formed descriptors (a single object or an array of objects) are also accepted.
 func (resp *responseWriteFile) UnmarshalJSON(data []byte) error {
-	// Declare an intermediate representation for the deformed response data.
+	// Declare an intermediate representation for the response data.
 	// The meta key of the response is not deformed but the data key is returned
 	// as an array of strings, with each string representing the string version
 	// of the JSON output for an array of FileDescriptors.
 	var ir struct {
 		Meta responseMetadata `json:"meta"`
-		Data []string         `json:"data"`
+		Data json.RawMessage  `json:"data"`
 	}
 
 	// Attempt to unmarshal data into the IR
@@ -146,27 +357,66 @@ func (resp *responseWriteFile) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("failed decode 'responseWriteFile' into intermediate representation: %w", err)
 	}
 
+	resp.Metadata = ir.Meta
+
+	// Error responses do not carry any file descriptors
+	if ir.Meta.StatusCode != 200 {
+		return nil
+	}
+
+	// Attempt to decode the file descriptors from all accepted forms
+	fileDescriptors, err := decodeWriteFileData(ir.Data)
+	if err != nil {
+		return err
+	}
+
+	if len(fileDescriptors) > 1 {
+		return fmt.Errorf("failed to decode 'data' into FileDescriptor: contains multiple descriptors")
+	}
+
+	// Update the response fields
+	if len(fileDescriptors) == 1 {
+		resp.Data = fileDescriptors[0]
+	}
+
+	return nil
+}
+
+// decodeWriteFileData decodes the data key of a responseWriteFile into a slice of FileDescriptors.
+// It accepts the deformed form (an array of strings), an array of descriptors or a single descriptor.
+func decodeWriteFileData(data json.RawMessage) ([]FileDescriptor, error) {
+	// Attempt to unmarshal the data as a single or an array of file descriptors
+	var descriptor FileDescriptor
+	if err := json.Unmarshal(data, &descriptor); err == nil {
+		return []FileDescriptor{descriptor}, nil
+	}
+
+	var descriptors []FileDescriptor
+	if err := json.Unmarshal(data, &descriptors); err == nil {
+		return descriptors, nil
+	}
+
+	// Attempt to unmarshal the data in the deformed form
+	var deformed []string
+	if err := json.Unmarshal(data, &deformed); err != nil {
+		return nil, fmt.Errorf("failed to decode 'data' into []string: %w", err)
+	}
+
 	// Iterate over the strings in the deformed data
 	fileDescriptors := make([]FileDescriptor, 0)
-	for _, descriptor := range ir.Data {
+	for _, descriptor := range deformed {
 		// Attempt to unmarshal each string into a slice of FileDescriptors
 		// Ideally, this should only be one string but this accommodates any further deformations.
 		var fd []FileDescriptor
 		if err := json.Unmarshal([]byte(descriptor), &fd); err != nil {
-			return fmt.Errorf("failed to decode 'data' into []FileDescriptor: %w", err)
+			return nil, fmt.Errorf("failed to decode 'data' into []FileDescriptor: %w", err)
 		}
 
 		// Append the file descriptors into the super set
 		fileDescriptors = append(fileDescriptors, fd...)
 	}
 
-	if len(fileDescriptors) > 1 {
-		return fmt.Errorf("failed to decode 'data' into FileDescriptor: contains multiple descriptors")
-	}
-
-	// Update the response fields
-	resp.Metadata, resp.Data = ir.Meta, fileDescriptors[0]
-	return nil
+	return fileDescriptors, nil
 }
 
 // WriteOption is a request option for the WriteFile method of Client.
@@ -226,12 +476,33 @@ func ApplyEncryption(encryption EncryptionType) WriteOption {
 	}
 }
 
+// BinaryUpload returns a WriteOption that can be used to upload the file as binary
+// data in a multipart form, even if the file data is valid UTF-8 text.
+func BinaryUpload() WriteOption {
+	return func(request *requestWriteFile) error {
+		request.binary = true
+		return nil
+	}
+}
+
+// RetryWrite returns a WriteOption that can be used to allow the write request to be retried
+// with the RetryPolicy of the client. Writes are not retried by default because they are not idempotent,
+// a retried write may create an additional version of the file if KeepPrevious is also used.
+func RetryWrite() WriteOption {
+	return func(request *requestWriteFile) error {
+		request.retry = true
+		return nil
+	}
+}
+
 // requestRemoveFile is the request for the RemoveFile API of MOIBit
 type requestRemoveFile struct {
 	FilePath    string `json:"path"`
 	Version     int    `json:"version"`
 	IsDirectory bool   `json:"isdir"`
 	Operation   int    `json:"operationType"`
+
+	retry bool
 }
 
 // defaultRemoveFileRequest generates a new requestRemoveFile object for the given file path and version
@@ -250,9 +521,15 @@ type responseRemoveFile struct {
 
 // RemoveFile removes a file at the given path of the specified version.
 // It also accepts a variadic number of RemoveOption to modify the remove request.
-// 		- To remove directories, use the path to the directory and pass the RemoveDirectory option.
-// 		- To restore files, pass the file path and version to restore with the PerformRestore option.
+//   - To remove directories, use the path to the directory and pass the RemoveDirectory option.
+//   - To restore files, pass the file path and version to restore with the PerformRestore option.
 func (client *Client) RemoveFile(path string, version int, opts ...RemoveOption) error {
+	return client.RemoveFileContext(context.Background(), path, version, opts...)
+}
+
+// RemoveFileContext removes a file at the given path of the specified version.
+// It behaves like RemoveFile but carries the given context on the outgoing request.
+func (client *Client) RemoveFileContext(ctx context.Context, path string, version int, opts ...RemoveOption) error {
 	// Generate Request Data
 	request := defaultRemoveFileRequest(path, version)
 	for _, opt := range opts {
@@ -268,7 +545,7 @@ func (client *Client) RemoveFile(path string, version int, opts ...RemoveOption)
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/remove"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/remove"), bytes.NewReader(requestData))
 	if err != nil {
 		return fmt.Errorf("request generation failed: %w", err)
 	}
@@ -277,21 +554,25 @@ func (client *Client) RemoveFile(path string, version int, opts ...RemoveOption)
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, request.retry)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseWriteFiles
 	response := new(responseRemoveFile)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return newDecodeError("/remove", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return fmt.Errorf("non-ok response [%v]: %v | %v", response.Metadata.StatusCode, response.Metadata.Message, response.Data)
+		apiErr := newAPIError("/remove", responseHTTP.StatusCode, response.Metadata)
+		apiErr.Detail = response.Data
+		return apiErr
 	}
 
 	return nil
@@ -318,6 +599,21 @@ func PerformRestore() RemoveOption {
 	}
 }
 
+// RetryRemove returns a RemoveOption that can be used to allow the remove request to be retried
+// with the RetryPolicy of the client. Removals are not retried by default because they are not idempotent,
+// a retried removal may fail with ErrNotFound if the first attempt was applied by MOIBit.
+func RetryRemove() RemoveOption {
+	return func(request *requestRemoveFile) error {
+		request.retry = true
+		return nil
+	}
+}
+
+// requestMakeDir is the request for the MakeDir API of MOIBit
+type requestMakeDir struct {
+	retry bool
+}
+
 // responseMakeDir is the response for the MakeDir API of MOIBit
 type responseMakeDir struct {
 	Metadata responseMetadata `json:"meta"`
@@ -325,9 +621,24 @@ type responseMakeDir struct {
 }
 
 // MakeDirectory creates a new directory at the given path which can than be used for storing files.
-func (client *Client) MakeDirectory(path string) error {
+// It also accepts a variadic number of MakeDirOption to modify the request.
+func (client *Client) MakeDirectory(path string, opts ...MakeDirOption) error {
+	return client.MakeDirectoryContext(context.Background(), path, opts...)
+}
+
+// MakeDirectoryContext creates a new directory at the given path.
+// It behaves like MakeDirectory but carries the given context on the outgoing request.
+func (client *Client) MakeDirectoryContext(ctx context.Context, path string, opts ...MakeDirOption) error {
+	// Generate Request Data
+	request := new(requestMakeDir)
+	for _, opt := range opts {
+		if err := opt(request); err != nil {
+			return fmt.Errorf("request creation failed while applying options: %w", err)
+		}
+	}
+
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("GET", client.serviceURL("/makedir"), nil)
+	requestHTTP, err := http.NewRequestWithContext(ctx, "GET", client.serviceURL("/makedir"), nil)
 	if err != nil {
 		return fmt.Errorf("request generation failed: %w", err)
 	}
@@ -341,22 +652,39 @@ func (client *Client) MakeDirectory(path string) error {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, request.retry)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseMakeDir
 	response := new(responseMakeDir)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return newDecodeError("/makedir", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return fmt.Errorf("non-ok response [%v]: %v | %v", response.Metadata.StatusCode, response.Metadata.Message, response.Data)
+		apiErr := newAPIError("/makedir", responseHTTP.StatusCode, response.Metadata)
+		apiErr.Detail = response.Data
+		return apiErr
 	}
 
 	return nil
 }
+
+// MakeDirOption is a request option for the MakeDirectory method of Client.
+type MakeDirOption func(*requestMakeDir) error
+
+// RetryMakeDir returns a MakeDirOption that can be used to allow the request to be retried
+// with the RetryPolicy of the client. Directory creations are not retried by default because they are not
+// idempotent, a retried creation may fail with ErrAlreadyExists if the first attempt was applied by MOIBit.
+func RetryMakeDir() MakeDirOption {
+	return func(request *requestMakeDir) error {
+		request.retry = true
+		return nil
+	}
+}
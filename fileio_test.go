@@ -0,0 +1,94 @@
+package moibit_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+func TestMakeDirectoryRetry(t *testing.T) {
+	server, client := newTestClient(t, moibit.Retry(testRetryPolicy))
+
+	// Directory creations are not retried by default
+	server.Inject(moibittest.Fault{Endpoint: "/makedir", Status: http.StatusServiceUnavailable})
+	var apiErr *moibit.APIError
+	if err := client.MakeDirectory("/once"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("MakeDirectory = %v, want the 503 APIError", err)
+	}
+
+	if calls := server.Calls("/makedir"); calls != 1 {
+		t.Errorf("%v calls to /makedir without RetryMakeDir, want 1", calls)
+	}
+
+	server.Inject(moibittest.Fault{Endpoint: "/makedir", Status: http.StatusServiceUnavailable, Times: 2})
+	if err := client.MakeDirectory("/retried", moibit.RetryMakeDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := server.Calls("/makedir"); calls != 4 {
+		t.Errorf("%v calls to /makedir, want 4", calls)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	_, client := newTestClient(t)
+
+	data := strings.Repeat("streamed line\n", 1000)
+	writeFiles(t, client, map[string]string{"/stream.txt": data})
+
+	file, err := client.OpenFile("/stream.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	// The size is reported from the Content-Length of the response
+	if file.Size != int64(len(data)) {
+		t.Errorf("OpenFile size = %v, want %v", file.Size, len(data))
+	}
+
+	read, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(read) != data {
+		t.Errorf("OpenFile read %v bytes, want %v", len(read), len(data))
+	}
+
+	if _, err := client.OpenFile("/missing.txt", 0); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("OpenFile of a missing file = %v, want %v", err, moibit.ErrNotFound)
+	}
+}
+
+func TestReadFileTo(t *testing.T) {
+	server, client := newTestClient(t)
+
+	data := strings.Repeat("streamed line\n", 1000)
+	writeFiles(t, client, map[string]string{"/stream.txt": data})
+
+	var buffer bytes.Buffer
+	written, err := client.ReadFileTo(&buffer, "/stream.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if written != int64(len(data)) || buffer.String() != data {
+		t.Errorf("ReadFileTo wrote %v bytes (%v buffered), want %v", written, buffer.Len(), len(data))
+	}
+
+	// A body that ends before its Content-Length fails the stream
+	server.Inject(moibittest.Fault{Endpoint: "/readfile", Truncate: 100})
+
+	buffer.Reset()
+	if written, err := client.ReadFileTo(&buffer, "/stream.txt", 0); err == nil || written != 100 {
+		t.Errorf("ReadFileTo of a truncated body = (%v, %v), want 100 bytes and an error", written, err)
+	}
+}
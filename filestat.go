@@ -2,9 +2,12 @@ package moibit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"time"
 )
 
 // FileVersionDescriptor describes the version information of file
@@ -22,6 +25,12 @@ type FileVersionDescriptor struct {
 	LastUpdated   string `json:"lastUpdated"`
 }
 
+// LastUpdatedTime returns the time at which the file version was last updated, parsed from its LastUpdated timestamp.
+// Returns the zero time if the timestamp cannot be parsed.
+func (version FileVersionDescriptor) LastUpdatedTime() time.Time {
+	return parseTimestamp(version.LastUpdated)
+}
+
 // FileDescriptor describes the status of file
 type FileDescriptor struct {
 	FileVersionDescriptor // inlined JSON
@@ -47,6 +56,22 @@ func (file FileDescriptor) Exists() bool {
 	return false
 }
 
+// FullPath returns the absolute path of the file or directory described by the FileDescriptor.
+// The path of a directory is held in its Directory, while the path of a file is the
+// concatenation of its Directory and its Path (the name of the file).
+func (file FileDescriptor) FullPath() string {
+	if file.IsDirectory {
+		return path.Join("/", file.Directory)
+	}
+
+	return path.Join("/", file.Directory, file.Path)
+}
+
+// Name returns the base name of the file or directory described by the FileDescriptor
+func (file FileDescriptor) Name() string {
+	return path.Base(file.FullPath())
+}
+
 // String implements the Stringer interface for FileDescriptor
 func (file FileDescriptor) String() string {
 	if file.IsDirectory {
@@ -75,6 +100,12 @@ type responseListFiles struct {
 // The files are returned as a slice of FileDescriptor objects.
 // An error is returned if the API fails or the client cannot authenticate with MOIBit.
 func (client *Client) ListFiles(path string) ([]FileDescriptor, error) {
+	return client.ListFilesContext(context.Background(), path)
+}
+
+// ListFilesContext lists the files for a specified path.
+// It behaves like ListFiles but carries the given context on the outgoing request.
+func (client *Client) ListFilesContext(ctx context.Context, path string) ([]FileDescriptor, error) {
 	// Generate Request Data
 	requestData, err := json.Marshal(requestListFiles{path})
 	if err != nil {
@@ -82,7 +113,7 @@ func (client *Client) ListFiles(path string) ([]FileDescriptor, error) {
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/listfiles"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/listfiles"), bytes.NewReader(requestData))
 	if err != nil {
 		return nil, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -91,21 +122,23 @@ func (client *Client) ListFiles(path string) ([]FileDescriptor, error) {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseListFiles
 	response := new(responseListFiles)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return nil, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return nil, newDecodeError("/listfiles", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return nil, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return nil, newAPIError("/listfiles", responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file descriptors from the response
@@ -127,6 +160,12 @@ type responseFileStatus struct {
 // The returned FileStatus is empty if the file does not exist, which can be checked with Exists().
 // An error is returned if the API fails or the client cannot authenticate with MOIBit.
 func (client *Client) FileStatus(path string) (FileDescriptor, error) {
+	return client.FileStatusContext(context.Background(), path)
+}
+
+// FileStatusContext returns the status of a file at a specified path.
+// It behaves like FileStatus but carries the given context on the outgoing request.
+func (client *Client) FileStatusContext(ctx context.Context, path string) (FileDescriptor, error) {
 	// Generate Request Data
 	requestData, err := json.Marshal(requestFileStatus{path})
 	if err != nil {
@@ -134,7 +173,7 @@ func (client *Client) FileStatus(path string) (FileDescriptor, error) {
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/filestatus"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/filestatus"), bytes.NewReader(requestData))
 	if err != nil {
 		return FileDescriptor{}, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -143,21 +182,23 @@ func (client *Client) FileStatus(path string) (FileDescriptor, error) {
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return FileDescriptor{}, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseFileStatus
 	response := new(responseFileStatus)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return FileDescriptor{}, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return FileDescriptor{}, newDecodeError("/filestatus", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return FileDescriptor{}, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return FileDescriptor{}, newAPIError("/filestatus", responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file descriptors from the response
@@ -178,6 +219,12 @@ type responseFileVersions struct {
 // FileVersions returns the version information of the file at the given path.
 // Returns a slice of FileVersionDescriptor objects, one for each version.
 func (client *Client) FileVersions(path string) ([]FileVersionDescriptor, error) {
+	return client.FileVersionsContext(context.Background(), path)
+}
+
+// FileVersionsContext returns the version information of the file at the given path.
+// It behaves like FileVersions but carries the given context on the outgoing request.
+func (client *Client) FileVersionsContext(ctx context.Context, path string) ([]FileVersionDescriptor, error) {
 	// Generate Request Data
 	requestData, err := json.Marshal(requestFileVersions{path})
 	if err != nil {
@@ -185,7 +232,7 @@ func (client *Client) FileVersions(path string) ([]FileVersionDescriptor, error)
 	}
 
 	// Generate Request Object
-	requestHTTP, err := http.NewRequest("POST", client.serviceURL("/versions"), bytes.NewReader(requestData))
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/versions"), bytes.NewReader(requestData))
 	if err != nil {
 		return nil, fmt.Errorf("request generation failed: %w", err)
 	}
@@ -194,21 +241,23 @@ func (client *Client) FileVersions(path string) ([]FileVersionDescriptor, error)
 	client.setHeaders(requestHTTP)
 
 	// Perform the HTTP Request
-	responseHTTP, err := client.c.Do(requestHTTP)
+	responseHTTP, err := client.do(requestHTTP, true)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
+	defer responseHTTP.Body.Close()
+
 	// Decode the response into a responseFileVersions
 	response := new(responseFileVersions)
 	decoder := json.NewDecoder(responseHTTP.Body)
 	if err := decoder.Decode(response); err != nil {
-		return nil, fmt.Errorf("response decode failed [HTTP %v]: %w", responseHTTP.StatusCode, err)
+		return nil, newDecodeError("/versions", responseHTTP.StatusCode, err)
 	}
 
 	// Check the status code of response
 	if response.Metadata.StatusCode != 200 {
-		return nil, fmt.Errorf("non-ok response [%v]: %v", response.Metadata.StatusCode, response.Metadata.Message)
+		return nil, newAPIError("/versions", responseHTTP.StatusCode, response.Metadata)
 	}
 
 	// Returns the file version descriptors from the response
@@ -0,0 +1,492 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FS is a read-only view of the files of an app on MOIBit as an fs.FS.
+// It implements fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS, so it can be used with
+// the standard library wherever a file system is accepted (fs.WalkDir, fs.Glob, http.FS, template.ParseFS).
+// Names are resolved relative to the root directory of the FS, as required by fs.ValidPath.
+//
+// The FS reports the stored size of files from their descriptors, without reading them. With ClientEncryption, or
+// with WithDecodedSizes for files compressed on the client, it reports the size of the data read through it instead,
+// which is resolved by opening the file (and reading it through if the size is not recorded in its header),
+// and cached by the hash of the file.
+type FS struct {
+	client  *Client
+	ctx     context.Context
+	root    string
+	opts    []ReadOption
+	decoded bool
+	sizes   *sync.Map
+}
+
+// FS returns an FS over the files of the app the client is configured for, rooted at the given directory.
+// The requests of the FS are performed with context.Background(), which can be changed with WithContext.
+func (client *Client) FS(root string) *FS {
+	return &FS{client: client, ctx: context.Background(), root: path.Join("/", root), sizes: new(sync.Map)}
+}
+
+// WithContext returns a copy of the FS that performs its requests with the given context
+func (fsys *FS) WithContext(ctx context.Context) *FS {
+	return &FS{client: fsys.client, ctx: ctx, root: fsys.root, opts: fsys.opts, decoded: fsys.decoded, sizes: fsys.sizes}
+}
+
+// WithReadOptions returns a copy of the FS that reads files with the given ReadOption, such as NoDecompress or Verify
+func (fsys *FS) WithReadOptions(opts ...ReadOption) *FS {
+	return &FS{client: fsys.client, ctx: fsys.ctx, root: fsys.root, opts: append(append([]ReadOption(nil), fsys.opts...), opts...), decoded: fsys.decoded, sizes: new(sync.Map)}
+}
+
+// WithDecodedSizes returns a copy of the FS that reports the size of the decompressed data of files compressed
+// on the client. Every file is opened to detect its compression header when its size is reported, which costs
+// a read request for each file that is described (by Stat or the Info of a directory entry).
+func (fsys *FS) WithDecodedSizes() *FS {
+	return &FS{client: fsys.client, ctx: fsys.ctx, root: fsys.root, opts: fsys.opts, decoded: true, sizes: new(sync.Map)}
+}
+
+// fullPath resolves the given name into an absolute path on MOIBit.
+// Returns an error if the name is not valid according to fs.ValidPath.
+func (fsys *FS) fullPath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return path.Join(fsys.root, name), nil
+}
+
+// pathError wraps an error from MOIBit for the given operation and name into an fs.PathError.
+// An error matching ErrNotFound is converted into fs.ErrNotExist.
+func pathError(op, name string, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		err = fs.ErrNotExist
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// stat returns the FileDescriptor for the file or directory at the given path
+func (fsys *FS) stat(op, name, fullpath string) (FileDescriptor, error) {
+	// The root directory always exists
+	if fullpath == "/" {
+		return FileDescriptor{IsDirectory: true}, nil
+	}
+
+	file, err := fsys.client.FileStatusContext(fsys.ctx, fullpath)
+	if err != nil {
+		return FileDescriptor{}, pathError(op, name, err)
+	}
+
+	if !file.Exists() {
+		return FileDescriptor{}, pathError(op, name, fs.ErrNotExist)
+	}
+
+	return file, nil
+}
+
+// transformed returns whether the sizes reported by the FS are resolved by opening the files, which is required
+// if the data read through the FS is decrypted or if the decoded sizes of compressed files are requested
+func (fsys *FS) transformed() bool {
+	request := new(requestReadFile)
+	for _, opt := range fsys.opts {
+		_ = opt(request)
+	}
+
+	return (fsys.client.keys != nil || (fsys.decoded && !request.noDecompress)) && !request.raw
+}
+
+// size returns the size of the file at the given path reported by the FS.
+// It is the FileSize of the file, unless the file is decrypted on the client or the FS reports decoded sizes,
+// in which case the file is opened to resolve the size of its data. Resolved sizes are cached by the hash of the file.
+func (fsys *FS) size(fullpath string, file FileDescriptor) (int64, error) {
+	if file.IsDirectory || !fsys.transformed() {
+		return int64(file.FileSize), nil
+	}
+
+	// Files that are too small for the compression header are read as they are stored
+	if fsys.client.keys == nil && file.FileSize < compressionHeaderSize {
+		return int64(file.FileSize), nil
+	}
+
+	if size, ok := fsys.sizes.Load(file.Hash); ok && file.Hash != "" {
+		return size.(int64), nil
+	}
+
+	reader, err := fsys.client.OpenFileContext(fsys.ctx, fullpath, 0, fsys.opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer reader.Close()
+
+	// Count the data if its size cannot be computed without reading it
+	size := reader.Size
+	if size < 0 {
+		if size, err = io.Copy(io.Discard, reader); err != nil {
+			return 0, err
+		}
+	}
+
+	if file.Hash != "" {
+		fsys.sizes.Store(file.Hash, size)
+	}
+
+	return size, nil
+}
+
+// info returns the fs.FileInfo of the given file at the given path with the size of the data read through the FS
+func (fsys *FS) info(op, name, fullpath string, file FileDescriptor) (fs.FileInfo, error) {
+	size, err := fsys.size(fullpath, file)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+
+	return fileInfo{file: file, size: size}, nil
+}
+
+// Open implements the fs.FS interface for FS.
+// Files are opened lazily, their data is requested from MOIBit on the first read.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	fullpath, err := fsys.fullPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.stat("open", name, fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.IsDirectory {
+		return &fsDirectory{fsys: fsys, name: name, path: fullpath, descriptor: file}, nil
+	}
+
+	return &fsFile{fsys: fsys, name: name, path: fullpath, descriptor: file, size: -1}, nil
+}
+
+// Stat implements the fs.StatFS interface for FS
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	fullpath, err := fsys.fullPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.stat("stat", name, fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.info("stat", name, fullpath, file)
+}
+
+// ReadDir implements the fs.ReadDirFS interface for FS.
+// The entries are returned sorted by their names.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fullpath, err := fsys.fullPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fsys.client.ListFilesContext(fsys.ctx, fullpath)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, &fsEntry{fsys: fsys, name: path.Join(name, file.Name()), path: path.Join(fullpath, file.Name()), descriptor: file})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile implements the fs.ReadFileFS interface for FS.
+// The latest version of the file is read with the ReadOption of the FS.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	fullpath, err := fsys.fullPath("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fsys.client.ReadFileContext(fsys.ctx, fullpath, 0, fsys.opts...)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+
+	return data, nil
+}
+
+// FileInfo returns the FileDescriptor as an fs.FileInfo.
+// The Sys method of the returned fs.FileInfo returns the FileDescriptor.
+func (file FileDescriptor) FileInfo() fs.FileInfo {
+	return fileInfo{file: file, size: int64(file.FileSize)}
+}
+
+// fileInfo is an fs.FileInfo for a FileDescriptor with the size of its data
+type fileInfo struct {
+	file FileDescriptor
+	size int64
+}
+
+// Name implements the fs.FileInfo interface for fileInfo
+func (info fileInfo) Name() string { return info.file.Name() }
+
+// Size implements the fs.FileInfo interface for fileInfo
+func (info fileInfo) Size() int64 { return info.size }
+
+// IsDir implements the fs.FileInfo interface for fileInfo
+func (info fileInfo) IsDir() bool { return info.file.IsDirectory }
+
+// ModTime implements the fs.FileInfo interface for fileInfo
+func (info fileInfo) ModTime() time.Time { return info.file.LastUpdatedTime() }
+
+// Sys implements the fs.FileInfo interface for fileInfo
+func (info fileInfo) Sys() interface{} { return info.file }
+
+// Mode implements the fs.FileInfo interface for fileInfo.
+// Files and directories on MOIBit are read-only through an FS.
+func (info fileInfo) Mode() fs.FileMode {
+	if info.file.IsDirectory {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// parseTimestamp parses a timestamp returned by MOIBit, which is either formatted as
+// RFC 3339, as a date and time separated by a space, or as a number of Unix seconds or milliseconds.
+// Returns the zero time if the timestamp cannot be parsed.
+func parseTimestamp(timestamp string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if parsed, err := time.Parse(layout, timestamp); err == nil {
+			return parsed
+		}
+	}
+
+	if number, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		// Timestamps after the year 2286 in seconds are assumed to be in milliseconds
+		if number > 1e10 {
+			return time.UnixMilli(number)
+		}
+
+		return time.Unix(number, 0)
+	}
+
+	return time.Time{}
+}
+
+// fsFile is an fs.File for a file on MOIBit.
+// It also implements io.Seeker, which is required to serve it with http.FS.
+type fsFile struct {
+	fsys       *FS
+	name       string
+	path       string
+	descriptor FileDescriptor
+
+	size   int64
+	reader io.ReadCloser
+	offset int64
+	closed bool
+}
+
+// Stat implements the fs.File interface for fsFile
+func (file *fsFile) Stat() (fs.FileInfo, error) {
+	size, err := file.dataSize("stat")
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{file: file.descriptor, size: size}, nil
+}
+
+// dataSize returns the size of the data read from the file, which is resolved on the first call
+func (file *fsFile) dataSize(op string) (int64, error) {
+	if file.size < 0 {
+		size, err := file.fsys.size(file.path, file.descriptor)
+		if err != nil {
+			return 0, pathError(op, file.name, err)
+		}
+
+		file.size = size
+	}
+
+	return file.size, nil
+}
+
+// Read implements the fs.File interface for fsFile.
+// The file is opened on MOIBit on the first read, or the first read after a seek.
+func (file *fsFile) Read(p []byte) (int, error) {
+	if file.closed {
+		return 0, &fs.PathError{Op: "read", Path: file.name, Err: fs.ErrClosed}
+	}
+
+	if file.reader == nil {
+		reader, err := file.fsys.client.OpenFileContext(file.fsys.ctx, file.path, 0, file.fsys.opts...)
+		if err != nil {
+			return 0, pathError("read", file.name, err)
+		}
+
+		// Skip the data up to the current offset
+		if _, err := io.CopyN(io.Discard, reader, file.offset); err != nil && err != io.EOF {
+			reader.Close()
+			return 0, pathError("read", file.name, err)
+		}
+
+		file.reader = reader
+	}
+
+	n, err := file.reader.Read(p)
+	file.offset += int64(n)
+	return n, err
+}
+
+// Seek implements the io.Seeker interface for fsFile.
+// Seeking to a new offset reopens the file on MOIBit on the next read.
+func (file *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if file.closed {
+		return 0, &fs.PathError{Op: "seek", Path: file.name, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += file.offset
+	case io.SeekEnd:
+		size, err := file.dataSize("seek")
+		if err != nil {
+			return 0, err
+		}
+
+		offset += size
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: file.name, Err: fs.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: file.name, Err: fs.ErrInvalid}
+	}
+
+	if offset != file.offset && file.reader != nil {
+		file.reader.Close()
+		file.reader = nil
+	}
+
+	file.offset = offset
+	return offset, nil
+}
+
+// Close implements the fs.File interface for fsFile
+func (file *fsFile) Close() error {
+	if file.closed {
+		return &fs.PathError{Op: "close", Path: file.name, Err: fs.ErrClosed}
+	}
+
+	file.closed = true
+	if file.reader != nil {
+		return file.reader.Close()
+	}
+
+	return nil
+}
+
+// fsEntry is an fs.DirEntry for a file or directory listed by an FS.
+// The size of its fs.FileInfo is resolved when Info is called.
+type fsEntry struct {
+	fsys       *FS
+	name       string
+	path       string
+	descriptor FileDescriptor
+}
+
+// Name implements the fs.DirEntry interface for fsEntry
+func (entry *fsEntry) Name() string { return entry.descriptor.Name() }
+
+// IsDir implements the fs.DirEntry interface for fsEntry
+func (entry *fsEntry) IsDir() bool { return entry.descriptor.IsDirectory }
+
+// Type implements the fs.DirEntry interface for fsEntry
+func (entry *fsEntry) Type() fs.FileMode { return entry.descriptor.FileInfo().Mode().Type() }
+
+// Info implements the fs.DirEntry interface for fsEntry
+func (entry *fsEntry) Info() (fs.FileInfo, error) {
+	return entry.fsys.info("stat", entry.name, entry.path, entry.descriptor)
+}
+
+// fsDirectory is an fs.ReadDirFile for a directory on MOIBit
+type fsDirectory struct {
+	fsys       *FS
+	name       string
+	path       string
+	descriptor FileDescriptor
+
+	entries []fs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+// Stat implements the fs.File interface for fsDirectory
+func (dir *fsDirectory) Stat() (fs.FileInfo, error) {
+	return dir.descriptor.FileInfo(), nil
+}
+
+// Read implements the fs.File interface for fsDirectory.
+// Always returns an error because a directory cannot be read.
+func (dir *fsDirectory) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.name, Err: fmt.Errorf("is a directory")}
+}
+
+// Close implements the fs.File interface for fsDirectory
+func (dir *fsDirectory) Close() error {
+	if dir.closed {
+		return &fs.PathError{Op: "close", Path: dir.name, Err: fs.ErrClosed}
+	}
+
+	dir.closed = true
+	return nil
+}
+
+// ReadDir implements the fs.ReadDirFile interface for fsDirectory.
+// The directory is listed on MOIBit on the first call.
+func (dir *fsDirectory) ReadDir(n int) ([]fs.DirEntry, error) {
+	if dir.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: dir.name, Err: fs.ErrClosed}
+	}
+
+	if !dir.listed {
+		entries, err := dir.fsys.ReadDir(dir.name)
+		if err != nil {
+			return nil, err
+		}
+
+		dir.entries, dir.listed = entries, true
+	}
+
+	// Return all remaining entries
+	if n <= 0 {
+		entries := dir.entries
+		dir.entries = nil
+		return entries, nil
+	}
+
+	if len(dir.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(dir.entries) {
+		n = len(dir.entries)
+	}
+
+	entries := dir.entries[:n]
+	dir.entries = dir.entries[n:]
+	return entries, nil
+}
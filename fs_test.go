@@ -0,0 +1,127 @@
+package moibit_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+func TestFSConformance(t *testing.T) {
+	_, client := newTestClient(t)
+
+	writeFiles(t, client, map[string]string{
+		"/a.txt":         "alpha",
+		"/dir/b.txt":     "bravo",
+		"/dir/sub/c.txt": "charlie",
+		"/empty.txt":     "",
+	})
+
+	if err := client.MakeDirectory("/vacant"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(client.FS("/"), "a.txt", "dir/b.txt", "dir/sub/c.txt", "empty.txt", "vacant"); err != nil {
+		t.Error(err)
+	}
+
+	if err := fstest.TestFS(client.FS("/dir"), "b.txt", "sub/c.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFSRoot(t *testing.T) {
+	_, client := newTestClient(t)
+
+	info, err := client.FS("/").Stat(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := info.Sys().(moibit.FileDescriptor)
+	if !info.IsDir() || root.FullPath() != "/" || root.String() != "[Dirc] /" {
+		t.Errorf("Stat(.) = %v (%v), want the root directory /", root, root.FullPath())
+	}
+
+	files, err := client.Glob("**")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].String() != "[Dirc] /" {
+		t.Errorf("Glob(**) = %v, want the root directory /", files)
+	}
+}
+
+func TestFSErrors(t *testing.T) {
+	_, client := newTestClient(t)
+	fsys := client.FS("/")
+
+	if _, err := fsys.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a missing file = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	if _, err := fsys.ReadFile("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of a missing file = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	for _, name := range []string{"/a.txt", "../a.txt", "a/./b", ""} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) = %v, want %v", name, err, fs.ErrInvalid)
+		}
+	}
+}
+
+func TestFSSizes(t *testing.T) {
+	server, client := newTestClient(t)
+
+	data := logData(100000)
+	if _, err := client.WriteFile(data, "/logs/app.json", moibit.Compress(moibit.GzipCompression)); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, client, map[string]string{"/logs/a.txt": "alpha text", "/logs/b.txt": "bravo text"})
+
+	// Sizes are reported from the listing, without reading the files
+	fsys := client.FS("/")
+	if info, err := fsys.Stat("logs/app.json"); err != nil || info.Size() >= int64(len(data)) {
+		t.Errorf("Stat(logs/app.json) = %v, want the size of the compressed data", err)
+	}
+
+	entries, err := fsys.ReadDir("logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if _, err := entry.Info(); err != nil {
+			t.Errorf("Info(%v) = %v", entry.Name(), err)
+		}
+	}
+
+	if calls := server.Calls("/readfile"); calls != 0 {
+		t.Errorf("Stat and ReadDir read %v files, want none", calls)
+	}
+
+	// Decoded sizes are resolved by reading each file once, since they are cached
+	decoded := client.FS("/").WithDecodedSizes()
+	if info, err := decoded.Stat("logs/app.json"); err != nil || info.Size() != int64(len(data)) {
+		t.Errorf("Stat(logs/app.json) with decoded sizes = %v, want the size of the uncompressed data %v", err, len(data))
+	}
+
+	if entries, err = decoded.ReadDir("logs"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if _, err := entry.Info(); err != nil {
+			t.Errorf("Info(%v) with decoded sizes = %v", entry.Name(), err)
+		}
+	}
+
+	if calls := server.Calls("/readfile"); calls != len(entries) {
+		t.Errorf("Stat and ReadDir with decoded sizes read %v files, want %v", calls, len(entries))
+	}
+}
@@ -0,0 +1,140 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns the files and directories on MOIBit that match the given pattern, ordered by their path.
+// The pattern is an absolute path whose elements follow the syntax of path.Match, with the addition of
+// the "**" element which matches zero or more directories (e.g. "/reports/2026-*/**/*.json").
+// The tree under the longest literal prefix of the pattern is walked with ListFiles, skipping the
+// directories that cannot contain a match. The only possible error is path.ErrBadPattern or an API error.
+func (client *Client) Glob(pattern string) ([]FileDescriptor, error) {
+	return client.GlobContext(context.Background(), pattern)
+}
+
+// GlobContext returns the files and directories on MOIBit that match the given pattern, ordered by their path.
+// It behaves like Glob but carries the given context on the outgoing requests.
+func (client *Client) GlobContext(ctx context.Context, pattern string) ([]FileDescriptor, error) {
+	elements := splitPath(path.Join("/", pattern))
+
+	// Validate the pattern and find its longest literal prefix
+	prefix := 0
+	for i, element := range elements {
+		if _, err := path.Match(element, ""); err != nil {
+			return nil, err
+		}
+
+		if prefix == i && !hasMeta(element) {
+			prefix++
+		}
+	}
+
+	// The last element of a literal pattern is the match itself, walk its directory
+	if prefix == len(elements) && prefix > 0 {
+		prefix--
+	}
+
+	root := "/" + strings.Join(elements[:prefix], "/")
+
+	type match struct {
+		path string
+		file FileDescriptor
+	}
+
+	matches := make([]match, 0)
+	err := client.Walk(ctx, root, func(filepath string, file FileDescriptor, err error) error {
+		if err != nil {
+			// A missing root matches nothing
+			if filepath == root && (errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrNotFound)) {
+				return fs.SkipAll
+			}
+
+			return err
+		}
+
+		segments := splitPath(filepath)
+		if matchElements(elements, segments) {
+			matches = append(matches, match{filepath, file})
+		}
+
+		// Skip directories that cannot contain a match
+		if file.IsDirectory && !matchPrefix(elements, segments) {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("glob walk failed: %w", err)
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+
+	files := make([]FileDescriptor, 0, len(matches))
+	for _, match := range matches {
+		files = append(files, match.file)
+	}
+
+	return files, nil
+}
+
+// splitPath splits an absolute path into its elements. The root path has no elements.
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// hasMeta returns whether the given pattern element contains any of the special characters of path.Match
+func hasMeta(element string) bool {
+	return strings.ContainsAny(element, `*?[\`)
+}
+
+// matchElements returns whether the given path elements match the given pattern elements.
+// The "**" pattern element matches zero or more path elements.
+func matchElements(pattern, elements []string) bool {
+	if len(pattern) == 0 {
+		return len(elements) == 0
+	}
+
+	if pattern[0] == "**" {
+		return matchElements(pattern[1:], elements) || (len(elements) > 0 && matchElements(pattern, elements[1:]))
+	}
+
+	if len(elements) == 0 {
+		return false
+	}
+
+	matched, _ := path.Match(pattern[0], elements[0])
+	return matched && matchElements(pattern[1:], elements[1:])
+}
+
+// matchPrefix returns whether the descendants of a directory with
+// the given path elements can match the given pattern elements.
+func matchPrefix(pattern, elements []string) bool {
+	if len(elements) == 0 {
+		return len(pattern) > 0
+	}
+
+	if len(pattern) == 0 {
+		return false
+	}
+
+	if pattern[0] == "**" {
+		return true
+	}
+
+	matched, _ := path.Match(pattern[0], elements[0])
+	return matched && matchPrefix(pattern[1:], elements[1:])
+}
@@ -0,0 +1,95 @@
+package moibit_test
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+func TestGlob(t *testing.T) {
+	server, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{
+		"/a.json":                        "{}",
+		"/notes.txt":                     "notes",
+		"/reports/2025-12/summary.json":  "{}",
+		"/reports/2026-01/summary.json":  "{}",
+		"/reports/2026-01/raw/data.json": "{}",
+		"/reports/2026-01/raw/data.csv":  "a,b",
+		"/reports/2026-02/summary.txt":   "summary",
+		"/logs/[old]/app.log":            "log",
+	})
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/*.json", []string{"/a.json"}},
+		{"*.json", []string{"/a.json"}},
+		{"/notes.txt", []string{"/notes.txt"}},
+		{"/missing.txt", []string{}},
+		{"/missing/*", []string{}},
+		{"/reports/*", []string{"/reports/2025-12", "/reports/2026-01", "/reports/2026-02"}},
+		{"/reports/2026-*/*.json", []string{"/reports/2026-01/summary.json"}},
+		{"/reports/2026-*/**/*.json", []string{"/reports/2026-01/raw/data.json", "/reports/2026-01/summary.json"}},
+		{"/**/*.json", []string{"/a.json", "/reports/2025-12/summary.json", "/reports/2026-01/raw/data.json", "/reports/2026-01/summary.json"}},
+		{"/**/raw", []string{"/reports/2026-01/raw"}},
+		{"/reports/**", []string{"/reports", "/reports/2025-12", "/reports/2025-12/summary.json", "/reports/2026-01",
+			"/reports/2026-01/raw", "/reports/2026-01/raw/data.csv", "/reports/2026-01/raw/data.json",
+			"/reports/2026-01/summary.json", "/reports/2026-02", "/reports/2026-02/summary.txt"}},
+		{"/reports/**/**/data.?sv", []string{"/reports/2026-01/raw/data.csv"}},
+		{"/logs/\\[old\\]/*.log", []string{"/logs/[old]/app.log"}},
+		{"/logs/[a-z]*", []string{}},
+	}
+
+	for _, test := range tests {
+		files, err := client.Glob(test.pattern)
+		if err != nil {
+			t.Errorf("Glob(%q) failed: %v", test.pattern, err)
+			continue
+		}
+
+		got := make([]string, 0, len(files))
+		for _, file := range files {
+			got = append(got, file.FullPath())
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Glob(%q) = %v, want %v", test.pattern, got, test.want)
+		}
+	}
+
+	// Directories that cannot contain a match are not listed
+	before := server.Calls("/listfiles")
+	if _, err := client.Glob("/reports/2026-02/*.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := server.Calls("/listfiles") - before; calls != 1 {
+		t.Errorf("Glob with a literal prefix listed %v directories, want 1", calls)
+	}
+}
+
+func TestGlobErrors(t *testing.T) {
+	server, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/a/b.txt": "b"})
+
+	for _, pattern := range []string{"/[", "/a/[b-", "/**/\\", "/a/[]"} {
+		if _, err := client.Glob(pattern); err != path.ErrBadPattern {
+			t.Errorf("Glob(%q) = %v, want %v", pattern, err, path.ErrBadPattern)
+		}
+	}
+
+	if calls := server.Calls("/listfiles"); calls != 0 {
+		t.Errorf("invalid patterns listed %v directories, want 0", calls)
+	}
+
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", Status: http.StatusForbidden})
+	if _, err := client.Glob("/a/*"); !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("Glob with a failed listing = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+}
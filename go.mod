@@ -1,3 +1,11 @@
 module github.com/manishmeganathan/go-moibit-client
 
-go 1.18
+go 1.20
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.1
+	golang.org/x/crypto v0.21.0
+)
+
+require golang.org/x/sys v0.18.0 // indirect
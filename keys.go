@@ -0,0 +1,437 @@
+package moibit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// passphraseKey is a KeyProvider that wraps data keys with keys derived from a passphrase with scrypt
+type passphraseKey struct {
+	id         string
+	passphrase []byte
+
+	salt []byte
+	key  []byte
+
+	mu    sync.Mutex
+	keys  map[string][]byte
+	order []string
+}
+
+const (
+	// saltSize is the size of the random salt of the keys derived from a passphrase
+	saltSize = 16
+
+	// scryptN, scryptR and scryptP are the cost parameters of the scrypt key derivation
+	scryptN, scryptR, scryptP = 1 << 15, 8, 1
+
+	// derivedKeysCached is the maximum number of keys derived with other salts that are cached by a passphraseKey
+	derivedKeysCached = 32
+)
+
+// PassphraseKey returns a KeyProvider that wraps data keys with a key derived from the given passphrase with scrypt.
+// The key is derived with a random salt once for every PassphraseKey, and the salt is stored with every wrapped data key.
+// Keys derived with other salts are derived again when they are unwrapped, the most recent of them are cached.
+func PassphraseKey(id, passphrase string) (KeyProvider, error) {
+	if id == "" {
+		return nil, fmt.Errorf("empty key id")
+	}
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+
+	provider := &passphraseKey{id: id, passphrase: []byte(passphrase), salt: make([]byte, saltSize), keys: make(map[string][]byte)}
+	if _, err := rand.Read(provider.salt); err != nil {
+		return nil, fmt.Errorf("salt generation failed: %w", err)
+	}
+
+	key, err := scrypt.Key(provider.passphrase, provider.salt, scryptN, scryptR, scryptP, dataKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	provider.key = key
+	return provider, nil
+}
+
+// derive returns the key derived from the passphrase with the given salt.
+// Keys derived with salts other than the salt of the provider are cached, the cache is
+// bounded by derivedKeysCached and the least recently derived key is evicted when it is full.
+func (provider *passphraseKey) derive(salt []byte) ([]byte, error) {
+	if bytes.Equal(salt, provider.salt) {
+		return provider.key, nil
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if key, ok := provider.keys[string(salt)]; ok {
+		return key, nil
+	}
+
+	key, err := scrypt.Key(provider.passphrase, salt, scryptN, scryptR, scryptP, dataKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	// Evict the oldest key if the cache is full
+	if len(provider.order) >= derivedKeysCached {
+		delete(provider.keys, provider.order[0])
+		provider.order = provider.order[1:]
+	}
+
+	provider.keys[string(salt)] = key
+	provider.order = append(provider.order, string(salt))
+	return key, nil
+}
+
+// KeyID implements the KeyProvider interface for passphraseKey
+func (provider *passphraseKey) KeyID() string {
+	return provider.id
+}
+
+// WrapKey implements the KeyProvider interface for passphraseKey.
+// The wrapped key is the salt of the derived key followed by the sealed data key.
+func (provider *passphraseKey) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	sealed, err := sealKey(provider.key, provider.id, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]byte(nil), provider.salt...), sealed...), nil
+}
+
+// UnwrapKey implements the KeyProvider interface for passphraseKey
+func (provider *passphraseKey) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != provider.id {
+		return nil, fmt.Errorf("unknown key id '%v'", keyID)
+	}
+
+	if len(wrapped) < saltSize {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	key, err := provider.derive(wrapped[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	return openKey(key, provider.id, wrapped[saltSize:])
+}
+
+// EnvKey returns a KeyProvider that wraps data keys with the 32 byte key in the given environment variable,
+// encoded as hex or standard base64. The ID of the key is the name of the variable prefixed with "env:".
+func EnvKey(variable string) (KeyProvider, error) {
+	value, ok := os.LookupEnv(variable)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable %v is not set", variable)
+	}
+
+	key, err := decodeKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %v: %w", variable, err)
+	}
+
+	return StaticKey("env:"+variable, key)
+}
+
+// decodeKey decodes a 32 byte key encoded as hex or standard base64
+func decodeKey(encoded string) ([]byte, error) {
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == dataKeySize {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == dataKeySize {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("key is not a hex or base64 encoded %v byte key", dataKeySize)
+}
+
+// KMS is the interface of an external key management service that encrypts and decrypts data keys with a master key
+// that never leaves the service. It can be implemented by adapting the client of any cloud KMS or HSM.
+type KMS interface {
+	// Encrypt encrypts the given plaintext with the master key of the given ID
+	Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+
+	// Decrypt decrypts the given ciphertext with the master key of the given ID
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// kmsKey is a KeyProvider that wraps data keys with a master key in a KMS
+type kmsKey struct {
+	kms KMS
+	id  string
+}
+
+// KMSKey returns a KeyProvider that wraps data keys with the master key of the given ID in the given KMS.
+// Data keys wrapped with other master keys of the KMS are also unwrapped, so rotating the master key
+// only requires a new KMSKey with the ID of the new key.
+func KMSKey(kms KMS, keyID string) (KeyProvider, error) {
+	if kms == nil {
+		return nil, fmt.Errorf("nil kms")
+	}
+
+	if keyID == "" {
+		return nil, fmt.Errorf("empty key id")
+	}
+
+	return &kmsKey{kms: kms, id: keyID}, nil
+}
+
+// KeyID implements the KeyProvider interface for kmsKey
+func (provider *kmsKey) KeyID() string {
+	return provider.id
+}
+
+// WrapKey implements the KeyProvider interface for kmsKey
+func (provider *kmsKey) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	return provider.kms.Encrypt(ctx, provider.id, dataKey)
+}
+
+// UnwrapKey implements the KeyProvider interface for kmsKey
+func (provider *kmsKey) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return provider.kms.Decrypt(ctx, keyID, wrapped)
+}
+
+// Keyring is a KeyProvider backed by a set of keys that can be stored in a local JSON file.
+// New data keys are wrapped with the primary key, while data keys wrapped with any key of the
+// Keyring can be unwrapped. Rotate adds a new primary key, and RewriteFileKey rewrites existing files
+// with their data key wrapped with it. A Keyring is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]keyringEntry
+}
+
+// keyringEntry is a key of a Keyring
+type keyringEntry struct {
+	Key     []byte    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+// keyringFile is the JSON file format of a Keyring
+type keyringFile struct {
+	Primary string                  `json:"primary"`
+	Keys    map[string]keyringEntry `json:"keys"`
+}
+
+// NewKeyring returns a new Keyring with a single random primary key
+func NewKeyring() (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string]keyringEntry)}
+	if _, err := keyring.Rotate(); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// LoadKeyring reads a Keyring from the JSON file at the given path
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := new(keyringFile)
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("keyring decode failed: %w", err)
+	}
+
+	if _, ok := file.Keys[file.Primary]; !ok {
+		return nil, fmt.Errorf("keyring primary key '%v' not found", file.Primary)
+	}
+
+	for id, entry := range file.Keys {
+		if len(entry.Key) != dataKeySize {
+			return nil, fmt.Errorf("keyring key '%v' has invalid size: %v bytes", id, len(entry.Key))
+		}
+	}
+
+	return &Keyring{primary: file.Primary, keys: file.Keys}, nil
+}
+
+// Save writes the Keyring to the JSON file at the given path, readable only by the owner.
+// The file is written to a temporary file which is renamed into place once complete.
+func (keyring *Keyring) Save(path string) error {
+	keyring.mu.RLock()
+	data, err := json.MarshalIndent(keyringFile{Primary: keyring.primary, Keys: keyring.keys}, "", "  ")
+	keyring.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("keyring encode failed: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary file unless it is renamed
+	defer os.Remove(temp.Name())
+
+	if err := temp.Chmod(0600); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// Rotate adds a new random key to the Keyring and makes it the primary key.
+// The previous keys are kept to unwrap the data keys of existing files.
+// Returns the ID of the new primary key.
+func (keyring *Keyring) Rotate() (string, error) {
+	key, id := make([]byte, dataKeySize), make([]byte, 8)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("key generation failed: %w", err)
+	}
+
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("key generation failed: %w", err)
+	}
+
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	keyID := hex.EncodeToString(id)
+	keyring.keys[keyID] = keyringEntry{Key: key, Created: time.Now().UTC()}
+	keyring.primary = keyID
+
+	return keyID, nil
+}
+
+// Remove removes the key of the given ID from the Keyring. The primary key cannot be removed.
+// Nothing checks whether files are still wrapped with the key: they can no longer be read once it is removed,
+// so every file encrypted under the key must be rewritten with RewriteFileKey before removing it.
+func (keyring *Keyring) Remove(keyID string) error {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	if keyID == keyring.primary {
+		return fmt.Errorf("primary key '%v' cannot be removed", keyID)
+	}
+
+	delete(keyring.keys, keyID)
+	return nil
+}
+
+// KeyID implements the KeyProvider interface for Keyring
+func (keyring *Keyring) KeyID() string {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	return keyring.primary
+}
+
+// WrapKey implements the KeyProvider interface for Keyring.
+// The data key is wrapped with the primary key.
+func (keyring *Keyring) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	keyring.mu.RLock()
+	id, entry := keyring.primary, keyring.keys[keyring.primary]
+	keyring.mu.RUnlock()
+
+	return sealKey(entry.Key, id, dataKey)
+}
+
+// UnwrapKey implements the KeyProvider interface for Keyring
+func (keyring *Keyring) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	keyring.mu.RLock()
+	entry, ok := keyring.keys[keyID]
+	keyring.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id '%v'", keyID)
+	}
+
+	return openKey(entry.Key, keyID, wrapped)
+}
+
+// RewriteFileKey rewrites the file encrypted on the client at the given path with its data key wrapped with the
+// current key of the KeyProvider of the client, such as the new primary key after a Keyring is rotated. The data key
+// is unwrapped and wrapped again while the ciphertext is copied as is, without being decrypted or encrypted again.
+//
+// Note: MOIBit cannot update a part of a file, so the whole file is downloaded (through a local temporary file)
+// and uploaded again with the given WriteOption, which costs a transfer of the file in both directions.
+// Returns false if the file is not encrypted or its data key is already wrapped with the current key.
+func (client *Client) RewriteFileKey(ctx context.Context, path string, opts ...WriteOption) (bool, error) {
+	if client.keys == nil {
+		return false, fmt.Errorf("client encryption is not enabled")
+	}
+
+	// Spool the encrypted file into a temporary file, so it is not read while it is written
+	temp, err := os.CreateTemp("", "moibit-rewrap-*")
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	if _, err := client.ReadFileToContext(ctx, temp, path, 0, Raw()); err != nil {
+		return false, err
+	}
+
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	// Decode the header of the file
+	encrypted := bufio.NewReader(temp)
+	if !isEncrypted(encrypted) {
+		return false, nil
+	}
+
+	header, err := readEnvelopeHeader(encrypted)
+	if err != nil {
+		return false, err
+	}
+
+	keyID := client.keys.KeyID()
+	if header.KeyID == keyID {
+		return false, nil
+	}
+
+	// Re-wrap the data key with the current key
+	dataKey, err := client.keys.UnwrapKey(ctx, header.KeyID, header.WrappedKey)
+	if err != nil {
+		return false, fmt.Errorf("data key could not be unwrapped with key '%v': %w", header.KeyID, err)
+	}
+
+	if header.WrappedKey, err = client.keys.WrapKey(ctx, dataKey); err != nil {
+		return false, fmt.Errorf("data key could not be wrapped: %w", err)
+	}
+
+	header.KeyID = keyID
+
+	// Write the new header followed by the unchanged ciphertext
+	data := io.MultiReader(bytes.NewReader(header.marshal()), encrypted)
+	if _, err := client.WriteFrom(ctx, data, path, append(append([]WriteOption(nil), opts...), rawWrite())...); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
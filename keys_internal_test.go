@@ -0,0 +1,35 @@
+package moibit
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPassphraseKeyCacheBounded(t *testing.T) {
+	provider, err := PassphraseKey("pass", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphrase := provider.(*passphraseKey)
+
+	// Keys derived with foreign salts are cached up to the bound, evicting the oldest
+	for i := 0; i <= derivedKeysCached; i++ {
+		if _, err := passphrase.derive(bytes.Repeat([]byte{byte(i)}, saltSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cached := len(passphrase.keys); cached != derivedKeysCached {
+		t.Errorf("%v cached keys, want %v", cached, derivedKeysCached)
+	}
+
+	if _, ok := passphrase.keys[string(bytes.Repeat([]byte{0}, saltSize))]; ok {
+		t.Error("the oldest derived key was not evicted")
+	}
+
+	// The key of the provider is never evicted
+	if key, _ := passphrase.derive(passphrase.salt); !bytes.Equal(key, passphrase.key) {
+		t.Error("the key of the provider was not returned for its salt")
+	}
+}
@@ -0,0 +1,310 @@
+package moibit_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// roundTrip writes a file with a client encrypting with the given KeyProvider and
+// returns a function that reads it with a client decrypting with another KeyProvider
+func roundTrip(t *testing.T, writer moibit.KeyProvider) func(reader moibit.KeyProvider) (string, error) {
+	t.Helper()
+
+	server, client := newTestClient(t, moibit.ClientEncryption(writer))
+	writeFiles(t, client, map[string]string{"/secret.txt": "secret"})
+
+	return func(reader moibit.KeyProvider) (string, error) {
+		client, err := server.NewClient(moibit.ClientEncryption(reader))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := client.ReadFile("/secret.txt", 0)
+		return string(data), err
+	}
+}
+
+func TestPassphraseKey(t *testing.T) {
+	provider, err := moibit.PassphraseKey("pass", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := roundTrip(t, provider)
+
+	// The salt is stored with the wrapped key, so another PassphraseKey derives the same key
+	other, err := moibit.PassphraseKey("pass", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := read(other); err != nil || data != "secret" {
+		t.Errorf("ReadFile with the same passphrase = %q, %v, want %q", data, err, "secret")
+	}
+
+	wrong, err := moibit.PassphraseKey("pass", "wrong passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := read(wrong); err == nil {
+		t.Errorf("ReadFile with a wrong passphrase = %q, want an error", data)
+	}
+
+	for _, args := range [][2]string{{"", "passphrase"}, {"pass", ""}} {
+		if _, err := moibit.PassphraseKey(args[0], args[1]); err == nil {
+			t.Errorf("PassphraseKey(%q, %q) succeeded, want an error", args[0], args[1])
+		}
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	t.Setenv("MOIBIT_TEST_HEX_KEY", hex.EncodeToString(key))
+	t.Setenv("MOIBIT_TEST_BASE64_KEY", base64.StdEncoding.EncodeToString(key))
+
+	hexKey, err := moibit.EnvKey("MOIBIT_TEST_HEX_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := hexKey.KeyID(); id != "env:MOIBIT_TEST_HEX_KEY" {
+		t.Errorf("KeyID() = %v, want env:MOIBIT_TEST_HEX_KEY", id)
+	}
+
+	// The same key in the same variable reads the file, whatever its encoding
+	t.Setenv("MOIBIT_TEST_HEX_KEY", base64.StdEncoding.EncodeToString(key))
+	base64Key, err := moibit.EnvKey("MOIBIT_TEST_HEX_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := roundTrip(t, hexKey)(base64Key); err != nil || data != "secret" {
+		t.Errorf("ReadFile with the base64 encoded key = %q, %v, want %q", data, err, "secret")
+	}
+
+	t.Setenv("MOIBIT_TEST_SHORT_KEY", hex.EncodeToString(key[:16]))
+	t.Setenv("MOIBIT_TEST_EMPTY_KEY", "")
+
+	for _, variable := range []string{"MOIBIT_TEST_SHORT_KEY", "MOIBIT_TEST_EMPTY_KEY", "MOIBIT_TEST_MISSING_KEY"} {
+		if _, err := moibit.EnvKey(variable); err == nil {
+			t.Errorf("EnvKey(%v) succeeded, want an error", variable)
+		}
+	}
+}
+
+// testKMS is a KMS that encrypts data keys with a static key for every master key ID
+type testKMS struct {
+	keys map[string]moibit.KeyProvider
+}
+
+// Encrypt implements the moibit.KMS interface for testKMS
+func (kms *testKMS) Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	key, ok := kms.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key '%v'", keyID)
+	}
+
+	return key.WrapKey(ctx, plaintext)
+}
+
+// Decrypt implements the moibit.KMS interface for testKMS
+func (kms *testKMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	key, ok := kms.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key '%v'", keyID)
+	}
+
+	return key.UnwrapKey(ctx, keyID, ciphertext)
+}
+
+func TestKMSKey(t *testing.T) {
+	kms := &testKMS{keys: map[string]moibit.KeyProvider{"master-1": staticKey(t, "master-1", 1), "master-2": staticKey(t, "master-2", 2)}}
+
+	first, err := moibit.KMSKey(kms, "master-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data keys wrapped with a previous master key are unwrapped after the rotation
+	second, err := moibit.KMSKey(kms, "master-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := roundTrip(t, first)
+	if data, err := read(second); err != nil || data != "secret" {
+		t.Errorf("ReadFile after the rotation = %q, %v, want %q", data, err, "secret")
+	}
+
+	// The data key cannot be unwrapped once the master key is gone
+	delete(kms.keys, "master-1")
+	if data, err := read(second); err == nil {
+		t.Errorf("ReadFile without the master key = %q, want an error", data)
+	}
+
+	if _, err := moibit.KMSKey(nil, "master-1"); err == nil {
+		t.Error("KMSKey with a nil KMS succeeded, want an error")
+	}
+
+	if _, err := moibit.KMSKey(kms, ""); err == nil {
+		t.Error("KMSKey with an empty key ID succeeded, want an error")
+	}
+}
+
+func TestKeyringSave(t *testing.T) {
+	keyring, err := moibit.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := keyring.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("keyring file mode = %v, want -rw-------", info.Mode().Perm())
+	}
+
+	loaded, err := moibit.LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.KeyID() != keyring.KeyID() {
+		t.Errorf("loaded primary key = %v, want %v", loaded.KeyID(), keyring.KeyID())
+	}
+
+	if data, err := roundTrip(t, keyring)(loaded); err != nil || data != "secret" {
+		t.Errorf("ReadFile with the loaded keyring = %q, %v, want %q", data, err, "secret")
+	}
+
+	// Saving again replaces the file without leaving temporary files
+	if err := loaded.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%v files next to the keyring, want only the keyring", len(entries))
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+	tests := map[string]string{
+		"invalid json":    `{"primary": `,
+		"missing primary": `{"primary": "b", "keys": {"a": {"key": "` + key + `"}}}`,
+		"short key":       `{"primary": "a", "keys": {"a": {"key": "` + key[:20] + `"}}}`,
+	}
+
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "keyring.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := moibit.LoadKeyring(path); err == nil {
+			t.Errorf("LoadKeyring with %v succeeded, want an error", name)
+		}
+	}
+
+	if _, err := moibit.LoadKeyring(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("LoadKeyring of a missing file = %v, want a not exist error", err)
+	}
+}
+
+func TestKeyringRotate(t *testing.T) {
+	keyring, err := moibit.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := newTestClient(t, moibit.ClientEncryption(keyring))
+	writeFiles(t, client, map[string]string{"/old.txt": "old", "/stale.txt": "stale"})
+	old := keyring.KeyID()
+
+	current, err := keyring.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current == old || keyring.KeyID() != current {
+		t.Fatalf("Rotate() = %v, want a new primary key instead of %v", current, old)
+	}
+
+	// Files written under the previous key can still be read
+	if data, err := client.ReadFile("/old.txt", 0); err != nil || string(data) != "old" {
+		t.Errorf("ReadFile after the rotation = %q, %v, want %q", data, err, "old")
+	}
+
+	// The options of the caller are not modified, even with spare capacity
+	opts := make([]moibit.WriteOption, 1, 2)
+	opts[0] = moibit.CreateFolders()
+
+	rewritten, err := client.RewriteFileKey(context.Background(), "/old.txt", opts...)
+	if err != nil || !rewritten {
+		t.Fatalf("RewriteFileKey() = %v, %v, want true", rewritten, err)
+	}
+
+	if opts[:2][1] != nil {
+		t.Error("RewriteFileKey() appended to the WriteOption of the caller")
+	}
+
+	if rewritten, err := client.RewriteFileKey(context.Background(), "/old.txt"); err != nil || rewritten {
+		t.Errorf("RewriteFileKey() of a rewritten file = %v, %v, want false", rewritten, err)
+	}
+
+	stored, err := client.ReadFile("/old.txt", 0, moibit.Raw())
+	if err != nil || !strings.Contains(string(stored), current) || strings.Contains(string(stored), old) {
+		t.Errorf("header of the rewritten file does not name the new primary key %v", current)
+	}
+
+	// Only the rewritten file can be read once the previous key is removed
+	if err := keyring.Remove(keyring.KeyID()); err == nil {
+		t.Error("Remove() of the primary key succeeded, want an error")
+	}
+
+	if err := keyring.Remove(old); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := client.ReadFile("/old.txt", 0); err != nil || string(data) != "old" {
+		t.Errorf("ReadFile of the rewritten file = %q, %v, want %q", data, err, "old")
+	}
+
+	if data, err := client.ReadFile("/stale.txt", 0); err == nil {
+		t.Errorf("ReadFile of a file under the removed key = %q, want an error", data)
+	}
+
+	// Files that are not encrypted are not rewritten
+	plain, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, plain, map[string]string{"/plain.txt": "plain"})
+	if rewritten, err := client.RewriteFileKey(context.Background(), "/plain.txt"); err != nil || rewritten {
+		t.Errorf("RewriteFileKey() of a plain file = %v, %v, want false", rewritten, err)
+	}
+}
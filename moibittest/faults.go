@@ -0,0 +1,247 @@
+package moibittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+// Fault describes a failure that is injected by a Server into the response of a request.
+// Faults are scripted with Inject and are applied in the order they were injected,
+// each to the next Times requests that match its Endpoint.
+type Fault struct {
+	// Endpoint restricts the fault to the requests for an endpoint (e.g. "/readfile").
+	// The fault applies to requests for every endpoint if it is empty.
+	Endpoint string
+	// Times is the number of requests the fault is applied to. The fault is applied once if it is 0.
+	Times int
+
+	// Latency delays the response by the given duration.
+	// The delay is aborted if the request is cancelled by the client.
+	Latency time.Duration
+	// Status replaces the response with an error response of the given status code.
+	Status int
+	// RetryAfter sets the Retry-After header (in seconds) on the error response of Status.
+	RetryAfter int
+	// ExpireAuth expires the session of the client, the request and all subsequent
+	// requests are rejected with 401 until the client authenticates again.
+	ExpireAuth bool
+
+	// Malformed replaces the body of the response with invalid JSON.
+	Malformed bool
+	// MetaCode replaces the code in the metadata of the response, while
+	// the HTTP status of the response remains unchanged.
+	MetaCode int
+	// Truncate cuts the body of the response after the given number of bytes
+	// while the Content-Length of the response still reports the complete body.
+	Truncate int
+}
+
+// faults is the fault injection state of a Server
+type faults struct {
+	script []*Fault
+	random *rand.Rand
+	rate   float64
+	calls  map[string]int
+
+	expired bool
+}
+
+// Inject appends the given faults to the fault script of the Server
+func (server *Server) Inject(faults ...Fault) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for _, fault := range faults {
+		fault := fault
+		if fault.Times == 0 {
+			fault.Times = 1
+		}
+
+		server.faults.script = append(server.faults.script, &fault)
+	}
+}
+
+// FailRandomly makes the Server fail requests (except authentication) randomly with a 500,
+// 502 or 503 response with the given probability. The failures are deterministic for a seed
+// and a sequence of requests. A probability of 0 disables random failures.
+func (server *Server) FailRandomly(seed int64, probability float64) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.faults.random = rand.New(rand.NewSource(seed))
+	server.faults.rate = probability
+}
+
+// ExpireAuth expires the session of the client immediately, unlike a Fault with ExpireAuth
+// which expires it when a matching request is received.
+func (server *Server) ExpireAuth() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.faults.expired = true
+}
+
+// ClearFaults removes all scripted faults and disables random failures.
+// It does not restore an expired session.
+func (server *Server) ClearFaults() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.faults.script = nil
+	server.faults.random, server.faults.rate = nil, 0
+}
+
+// Calls returns the number of requests received by the Server for the given endpoint (e.g. "/listfiles")
+func (server *Server) Calls(endpoint string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.faults.calls[endpoint]
+}
+
+// next returns the fault to apply to a request for the given endpoint, or nil if there is none.
+// Scripted faults are consumed before random failures are considered.
+func (server *Server) next(endpoint string) *Fault {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.faults.calls == nil {
+		server.faults.calls = make(map[string]int)
+	}
+
+	server.faults.calls[endpoint]++
+
+	// Find the first scripted fault for the endpoint
+	for i, fault := range server.faults.script {
+		if fault.Endpoint != "" && fault.Endpoint != endpoint {
+			continue
+		}
+
+		applied := *fault
+		if fault.Times--; fault.Times == 0 {
+			server.faults.script = append(server.faults.script[:i], server.faults.script[i+1:]...)
+		}
+
+		return &applied
+	}
+
+	// Roll for a random failure
+	if server.faults.random != nil && endpoint != "/user/auth" && server.faults.random.Float64() < server.faults.rate {
+		statuses := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+		return &Fault{Status: statuses[server.faults.random.Intn(len(statuses))]}
+	}
+
+	return nil
+}
+
+// inject wraps the handler of the Server with the fault injection
+func (server *Server) inject(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault := server.next(r.URL.Path)
+		if fault == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		// Delay the response
+		if fault.Latency > 0 {
+			timer := time.NewTimer(fault.Latency)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		// Replace the response with an error
+		switch {
+		case fault.ExpireAuth:
+			server.ExpireAuth()
+			server.fail(w, http.StatusUnauthorized, "session expired")
+			return
+
+		case fault.Status != 0:
+			if fault.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+			}
+
+			server.fail(w, fault.Status, fmt.Sprintf("injected fault [%v]", fault.Status))
+			return
+
+		case !fault.Malformed && fault.MetaCode == 0 && fault.Truncate == 0:
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		// Record the response to modify its body
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		body := recorder.Body.Bytes()
+
+		if fault.MetaCode != 0 {
+			body = replaceMetaCode(body, fault.MetaCode)
+		}
+
+		if fault.Malformed {
+			body = []byte(`{"meta": {"code": 200, "message": `)
+		}
+
+		// Write the recorded response with the modified body
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if fault.Truncate > 0 && fault.Truncate < len(body) {
+			body = body[:fault.Truncate]
+		}
+
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(body)
+	})
+}
+
+// replaceMetaCode replaces the code in the metadata of a JSON response body.
+// The metadata is kept as the first field, like in every response of the Server.
+// The body is returned unmodified if it is not a JSON object.
+func replaceMetaCode(body []byte, code int) []byte {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+
+	var meta map[string]interface{}
+	_ = json.Unmarshal(response["meta"], &meta)
+	if meta == nil {
+		meta = make(map[string]interface{})
+	}
+
+	meta["code"] = code
+	delete(response, "meta")
+
+	encodedMeta, err := json.Marshal(meta)
+	if err != nil {
+		return body
+	}
+
+	fields, err := json.Marshal(response)
+	if err != nil {
+		return body
+	}
+
+	// Insert the metadata before the other fields
+	modified := append([]byte(`{"meta":`), encodedMeta...)
+	if len(response) > 0 {
+		modified = append(append(modified, ','), fields[1:]...)
+	} else {
+		modified = append(modified, '}')
+	}
+
+	return append(modified, '\n')
+}
@@ -0,0 +1,234 @@
+package moibittest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// retryPolicy is a RetryPolicy with short delays for tests, Retry-After delays are capped by its MaxDelay
+var retryPolicy = moibit.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond}
+
+func TestStatusFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  moibittest.Fault
+		retry  bool
+		status int
+		match  error
+		calls  int
+	}{
+		{"503 not retried", moibittest.Fault{Status: http.StatusServiceUnavailable}, false, http.StatusServiceUnavailable, nil, 1},
+		{"503 retried", moibittest.Fault{Status: http.StatusServiceUnavailable, Times: 2}, true, 0, nil, 3},
+		{"503 exhausted", moibittest.Fault{Status: http.StatusServiceUnavailable, Times: 3}, true, http.StatusServiceUnavailable, nil, 3},
+		{"429 not retried", moibittest.Fault{Status: http.StatusTooManyRequests}, false, http.StatusTooManyRequests, moibit.ErrRateLimited, 1},
+		{"429 retried", moibittest.Fault{Status: http.StatusTooManyRequests}, true, 0, nil, 2},
+		{"429 retried after", moibittest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1}, true, 0, nil, 2},
+		{"429 exhausted after", moibittest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1, Times: 5}, true, http.StatusTooManyRequests, moibit.ErrRateLimited, 3},
+		{"404 not retryable", moibittest.Fault{Status: http.StatusNotFound}, true, http.StatusNotFound, moibit.ErrNotFound, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var opts []moibit.ClientOption
+			if test.retry {
+				opts = append(opts, moibit.Retry(retryPolicy))
+			}
+
+			server, client := newClient(t, opts...)
+
+			fault := test.fault
+			fault.Endpoint = "/listfiles"
+			server.Inject(fault)
+
+			start := time.Now()
+			_, err := client.ListFiles("/")
+
+			if calls := server.Calls("/listfiles"); calls != test.calls {
+				t.Errorf("%v calls to /listfiles, want %v", calls, test.calls)
+			}
+
+			// Retry-After delays are capped by the MaxDelay of the policy
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("request took %v, want the Retry-After capped by MaxDelay", elapsed)
+			}
+
+			if test.status == 0 {
+				if err != nil {
+					t.Errorf("ListFiles = %v, want success", err)
+				}
+
+				return
+			}
+
+			var apiErr *moibit.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status {
+				t.Fatalf("ListFiles = %v, want an APIError with status %v", err, test.status)
+			}
+
+			if test.match != nil && !errors.Is(err, test.match) {
+				t.Errorf("ListFiles = %v, want %v", err, test.match)
+			}
+		})
+	}
+}
+
+func TestMetaCodeFault(t *testing.T) {
+	tests := []struct {
+		code  int
+		match error
+	}{
+		{http.StatusNotFound, moibit.ErrNotFound},
+		{http.StatusForbidden, moibit.ErrUnauthorized},
+		{http.StatusConflict, moibit.ErrAlreadyExists},
+		{http.StatusPaymentRequired, moibit.ErrQuotaExceeded},
+		{http.StatusTooManyRequests, moibit.ErrRateLimited},
+	}
+
+	for _, test := range tests {
+		server, client := newClient(t)
+		mustWrite(t, client, "/a.txt", "alpha")
+
+		// The HTTP status remains 200 while the metadata reports the error
+		server.Inject(moibittest.Fault{Endpoint: "/filestatus", MetaCode: test.code})
+
+		_, err := client.FileStatus("/a.txt")
+		if !errors.Is(err, test.match) {
+			t.Errorf("FileStatus with meta code %v = %v, want %v", test.code, err, test.match)
+		}
+
+		var apiErr *moibit.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusOK || apiErr.Code != test.code {
+			t.Errorf("FileStatus with meta code %v = %+v, want HTTP 200 with code %v", test.code, apiErr, test.code)
+		}
+	}
+}
+
+func TestMalformedFault(t *testing.T) {
+	for _, endpoint := range []string{"/listfiles", "/filestatus", "/versions"} {
+		server, client := newClient(t)
+		mustWrite(t, client, "/a.txt", "alpha")
+		server.Inject(moibittest.Fault{Endpoint: endpoint, Malformed: true})
+
+		var err error
+		switch endpoint {
+		case "/listfiles":
+			_, err = client.ListFiles("/")
+		case "/filestatus":
+			_, err = client.FileStatus("/a.txt")
+		case "/versions":
+			_, err = client.FileVersions("/a.txt")
+		}
+
+		var apiErr *moibit.APIError
+		if !errors.As(err, &apiErr) || apiErr.Endpoint != endpoint || apiErr.StatusCode != http.StatusOK || apiErr.Err == nil {
+			t.Errorf("%v with a malformed response = %v, want a decode APIError", endpoint, err)
+		}
+
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%v with a malformed response = %v, want it to wrap %v", endpoint, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestTruncateFault(t *testing.T) {
+	server, client := newClient(t)
+	mustWrite(t, client, "/a.txt", "the complete contents of the file")
+
+	server.Inject(moibittest.Fault{Endpoint: "/readfile", Truncate: 8})
+	if data, err := client.ReadFile("/a.txt", 0); err == nil {
+		t.Errorf("ReadFile of a truncated response = %q, want an error", data)
+	}
+
+	server.Inject(moibittest.Fault{Endpoint: "/readfile", Truncate: 8})
+	_, err := client.ReadFile("/a.txt", 0, moibit.Verify())
+
+	var integrityErr *moibit.IntegrityError
+	if !errors.As(err, &integrityErr) || !errors.Is(err, moibit.ErrIntegrity) {
+		t.Fatalf("verified ReadFile of a truncated response = %v, want an IntegrityError", err)
+	}
+
+	if integrityErr.ActualSize != 8 || integrityErr.ExpectedSize != 33 {
+		t.Errorf("IntegrityError = %+v, want 8 of 33 bytes", integrityErr)
+	}
+}
+
+func TestExpireAuthFault(t *testing.T) {
+	var mu sync.Mutex
+	var reauthentications []error
+
+	server, client := newClient(t, moibit.OnReauthenticate(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		reauthentications = append(reauthentications, err)
+	}))
+
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", ExpireAuth: true})
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Fatalf("ListFiles with an expired session = %v, want it replayed", err)
+	}
+
+	if calls := server.Calls("/listfiles"); calls != 2 {
+		t.Errorf("%v calls to /listfiles, want 2", calls)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 2 {
+		t.Errorf("%v calls to /user/auth, want 2", calls)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reauthentications) != 1 || reauthentications[0] != nil {
+		t.Errorf("OnReauthenticate called with %v, want one successful re-authentication", reauthentications)
+	}
+}
+
+func TestFailRandomly(t *testing.T) {
+	failures := func() []bool {
+		server, client := newClient(t)
+		server.FailRandomly(42, 0.5)
+
+		results := make([]bool, 20)
+		for i := range results {
+			_, err := client.ListFiles("/")
+			results[i] = err != nil
+		}
+
+		return results
+	}
+
+	first, second := failures(), failures()
+	failed := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("random failures differ for the same seed: %v and %v", first, second)
+		}
+
+		if first[i] {
+			failed++
+		}
+	}
+
+	if failed == 0 || failed == len(first) {
+		t.Errorf("%v of %v requests failed with probability 0.5", failed, len(first))
+	}
+}
+
+func TestClearFaults(t *testing.T) {
+	server, client := newClient(t)
+	server.Inject(moibittest.Fault{Status: http.StatusInternalServerError, Times: 10})
+	server.FailRandomly(1, 1)
+	server.ClearFaults()
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Errorf("ListFiles after ClearFaults = %v", err)
+	}
+}
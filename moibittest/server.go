@@ -0,0 +1,821 @@
+// Package moibittest provides an in-memory fake of the MOIBit API for hermetic tests.
+//
+// A Server serves the MOIBit endpoints from an httptest.Server and stores files, versions and
+// directories in memory, isolated per app ID. Clients are pointed at it with the BaseURL option:
+//
+//	server := moibittest.NewServer()
+//	defer server.Close()
+//
+//	client, err := server.NewClient(moibit.AppID("my-app"))
+//
+// Failures can be scripted with Inject to exercise the error handling of a client:
+//
+//	server.Inject(
+//		moibittest.Fault{Endpoint: "/listfiles", Status: http.StatusServiceUnavailable, Times: 2},
+//		moibittest.Fault{Endpoint: "/readfile", Truncate: 16},
+//	)
+package moibittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+const (
+	// DefaultSignature is the signature accepted by a Server unless it is changed
+	DefaultSignature = "moibittest-signature"
+
+	// DefaultNonce is the nonce accepted by a Server unless it is changed
+	DefaultNonce = "moibittest-nonce"
+
+	// DefaultDeveloperKey is the public key returned by a Server for
+	// successful authentication, unless it is changed
+	DefaultDeveloperKey = "0x0000000000000000000000000000000000000001"
+)
+
+// Server is an in-memory fake of the MOIBit API.
+// The Signature, Nonce and DeveloperKey fields define the credentials accepted by the
+// server and must only be modified before the server receives any requests.
+//
+// If VerifySignatures is set, the server accepts any nonce with a valid personal signature
+// of the account whose address is the DeveloperKey instead of the fixed Signature and Nonce,
+// which allows a Client created with moibit.NewClientWithSigner to authenticate.
+type Server struct {
+	*httptest.Server
+
+	Signature        string
+	Nonce            string
+	DeveloperKey     string
+	VerifySignatures bool
+
+	mu       sync.Mutex
+	apps     map[string]*storage
+	faults   faults
+	requests int
+}
+
+// NewServer starts and returns a new Server with the default credentials.
+// The caller must call Close when finished, to shut it down.
+func NewServer() *Server {
+	server := &Server{
+		Signature:    DefaultSignature,
+		Nonce:        DefaultNonce,
+		DeveloperKey: DefaultDeveloperKey,
+		apps:         make(map[string]*storage),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/auth", server.handleAuth)
+	mux.HandleFunc("/listfiles", server.authorized(server.handleListFiles))
+	mux.HandleFunc("/filestatus", server.authorized(server.handleFileStatus))
+	mux.HandleFunc("/versions", server.authorized(server.handleVersions))
+	mux.HandleFunc("/readfile", server.authorized(server.handleReadFile))
+	mux.HandleFunc("/writetexttofile", server.authorized(server.handleWriteTextToFile))
+	mux.HandleFunc("/writefile", server.authorized(server.handleWriteFile))
+	mux.HandleFunc("/remove", server.authorized(server.handleRemove))
+	mux.HandleFunc("/makedir", server.authorized(server.handleMakeDir))
+	mux.HandleFunc("/appdetails", server.authorized(server.handleAppDetails))
+	mux.HandleFunc("/devstat", server.authorized(server.handleDevStat))
+
+	server.Server = httptest.NewServer(server.inject(mux))
+	return server
+}
+
+// ClientOptions returns the ClientOption required for a Client to use the Server
+func (server *Server) ClientOptions() []moibit.ClientOption {
+	return []moibit.ClientOption{moibit.BaseURL(server.URL)}
+}
+
+// NewClient creates a new Client authenticated with the credentials of the Server.
+// The given ClientOption are applied after the options that point the Client to the Server.
+func (server *Server) NewClient(opts ...moibit.ClientOption) (*moibit.Client, error) {
+	return moibit.NewClient(server.Signature, server.Nonce, append(server.ClientOptions(), opts...)...)
+}
+
+// responseMetadata is the metadata attached to every response of the Server
+type responseMetadata struct {
+	StatusCode int    `json:"code"`
+	RequestID  string `json:"requestID"`
+	Message    string `json:"message"`
+}
+
+// respond writes a JSON response with the given status code, message and data.
+// The status code is used for both the HTTP status and the metadata of the response.
+func (server *Server) respond(w http.ResponseWriter, code int, message string, data interface{}) {
+	server.mu.Lock()
+	server.requests++
+	requestID := fmt.Sprintf("moibittest-%d", server.requests)
+	server.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Metadata responseMetadata `json:"meta"`
+		Data     interface{}      `json:"data"`
+	}{responseMetadata{code, requestID, message}, data})
+}
+
+// fail writes a JSON error response with the given status code and message
+func (server *Server) fail(w http.ResponseWriter, code int, message string) {
+	server.respond(w, code, message, nil)
+}
+
+// handleAuth handles the /user/auth endpoint
+func (server *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	if !server.validCredentials(r) {
+		server.fail(w, http.StatusUnauthorized, "invalid signature or nonce")
+		return
+	}
+
+	// Authentication restores an expired session
+	server.mu.Lock()
+	server.faults.expired = false
+	server.mu.Unlock()
+
+	server.respond(w, http.StatusOK, "user authenticated", map[string]string{
+		"address": server.DeveloperKey,
+		"entropy": "",
+	})
+}
+
+// validCredentials returns whether the signature and nonce headers of the given request are accepted by the server
+func (server *Server) validCredentials(r *http.Request) bool {
+	signature, nonce := r.Header.Get("signature"), r.Header.Get("nonce")
+	if !server.VerifySignatures {
+		return signature == server.Signature && nonce == server.Nonce
+	}
+
+	address, err := moibit.RecoverAddress(nonce, signature)
+	return err == nil && strings.EqualFold(address, server.DeveloperKey)
+}
+
+// authorized wraps a handler with the validation of the authentication headers
+func (server *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !server.validCredentials(r) || !strings.EqualFold(r.Header.Get("developerKey"), server.DeveloperKey) {
+			server.fail(w, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+
+		server.mu.Lock()
+		expired := server.faults.expired
+		server.mu.Unlock()
+
+		if expired {
+			server.fail(w, http.StatusUnauthorized, "session expired")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// storage returns the storage of the app for the given request, creating it if required.
+// The lock of the server must be held by the caller.
+func (server *Server) storage(r *http.Request) *storage {
+	appID := r.Header.Get("appID")
+	store, ok := server.apps[appID]
+	if !ok {
+		store = newStorage()
+		server.apps[appID] = store
+	}
+
+	return store
+}
+
+// decode decodes the JSON body of a request into the given object
+func (server *Server) decode(w http.ResponseWriter, r *http.Request, object interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(object); err != nil {
+		server.fail(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return false
+	}
+
+	return true
+}
+
+// handleListFiles handles the /listfiles endpoint
+func (server *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	files, err := server.storage(r).list(cleanPath(request.Path))
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	server.respond(w, http.StatusOK, "files listed", files)
+}
+
+// handleFileStatus handles the /filestatus endpoint.
+// An empty descriptor is returned for a path that does not exist.
+func (server *Server) handleFileStatus(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	status := server.storage(r).status(cleanPath(request.Path))
+	server.mu.Unlock()
+
+	server.respond(w, http.StatusOK, "file status", status)
+}
+
+// handleVersions handles the /versions endpoint
+func (server *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	versions, err := server.storage(r).versions(cleanPath(request.Path))
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	server.respond(w, http.StatusOK, "file versions", versions)
+}
+
+// handleReadFile handles the /readfile endpoint.
+// The raw data of the file is returned for a successful read.
+func (server *Server) handleReadFile(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		FileName string `json:"fileName"`
+		Version  int    `json:"version"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	data, err := server.storage(r).read(cleanPath(request.FileName), request.Version)
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// writeRequest is the set of parameters for a write to the Server
+type writeRequest struct {
+	FileName      string `json:"fileName"`
+	KeepPrevious  bool   `json:"keepPrevious"`
+	CreateFolders bool   `json:"createFolders"`
+	IsProvenance  bool   `json:"isProvenance"`
+	Replication   int    `json:"replication"`
+}
+
+// handleWriteTextToFile handles the /writetexttofile endpoint.
+// The response reproduces the deformed shape of the MOIBit API, where the data is an array
+// of strings each holding the JSON encoding of an array of file descriptors.
+func (server *Server) handleWriteTextToFile(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		writeRequest
+		Text string `json:"text"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	descriptor, err := server.storage(r).write(request.writeRequest, []byte(request.Text))
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	deformed, _ := json.Marshal([]moibit.FileDescriptor{descriptor})
+	server.respond(w, http.StatusOK, "file written", []string{string(deformed)})
+}
+
+// handleWriteFile handles the /writefile endpoint with a multipart form
+func (server *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		server.fail(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart form: %v", err))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		server.fail(w, http.StatusBadRequest, fmt.Sprintf("missing file: %v", err))
+		return
+	}
+
+	defer file.Close()
+
+	data := new(bytes.Buffer)
+	if _, err := data.ReadFrom(file); err != nil {
+		server.fail(w, http.StatusBadRequest, fmt.Sprintf("invalid file: %v", err))
+		return
+	}
+
+	request := writeRequest{
+		FileName:      r.FormValue("fileName"),
+		KeepPrevious:  r.FormValue("keepPrevious") == "true",
+		CreateFolders: r.FormValue("createFolders") == "true",
+		IsProvenance:  r.FormValue("isProvenance") == "true",
+	}
+
+	_, _ = fmt.Sscan(r.FormValue("replication"), &request.Replication)
+
+	server.mu.Lock()
+	descriptor, failure := server.storage(r).write(request, data.Bytes())
+	server.mu.Unlock()
+
+	if failure != nil {
+		server.fail(w, failure.code, failure.message)
+		return
+	}
+
+	server.respond(w, http.StatusOK, "file written", []moibit.FileDescriptor{descriptor})
+}
+
+// handleRemove handles the /remove endpoint for both removal and restoration
+func (server *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		FilePath    string `json:"path"`
+		Version     int    `json:"version"`
+		IsDirectory bool   `json:"isdir"`
+		Operation   int    `json:"operationType"`
+	}
+
+	if !server.decode(w, r, &request) {
+		return
+	}
+
+	server.mu.Lock()
+	store, filepath := server.storage(r), cleanPath(request.FilePath)
+
+	var err *failure
+	switch {
+	case request.Operation == 1:
+		err = store.restore(filepath, request.Version)
+	case request.IsDirectory:
+		err = store.removeDirectory(filepath)
+	default:
+		err = store.remove(filepath, request.Version)
+	}
+
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	if request.Operation == 1 {
+		server.respond(w, http.StatusOK, "file restored", "restored "+filepath)
+	} else {
+		server.respond(w, http.StatusOK, "file removed", "removed "+filepath)
+	}
+}
+
+// handleMakeDir handles the /makedir endpoint
+func (server *Server) handleMakeDir(w http.ResponseWriter, r *http.Request) {
+	dirpath := cleanPath(r.URL.Query().Get("path"))
+
+	server.mu.Lock()
+	err := server.storage(r).makeDirectory(dirpath)
+	server.mu.Unlock()
+
+	if err != nil {
+		server.fail(w, err.code, err.message)
+		return
+	}
+
+	server.respond(w, http.StatusOK, "directory created", "created "+dirpath)
+}
+
+// handleAppDetails handles the /appdetails endpoint
+func (server *Server) handleAppDetails(w http.ResponseWriter, r *http.Request) {
+	appID := r.Header.Get("appID")
+	if appID == "" {
+		server.fail(w, http.StatusBadRequest, "missing appID")
+		return
+	}
+
+	server.respond(w, http.StatusOK, "app details", server.app(appID, r.Header.Get("networkID")))
+}
+
+// app returns the AppDescriptor of the app with the given ID on the given network
+func (server *Server) app(appID, networkID string) moibit.AppDescriptor {
+	return moibit.AppDescriptor{
+		IsActive:    true,
+		AppID:       appID,
+		AppName:     appID,
+		NetworkID:   networkID,
+		NetworkName: "moibittest",
+		Replication: 1,
+	}
+}
+
+// handleDevStat handles the /devstat endpoint
+func (server *Server) handleDevStat(w http.ResponseWriter, r *http.Request) {
+	dev := moibit.DevDescriptor{
+		Active:   true,
+		IsActive: true,
+		Name:     "moibittest",
+		Email:    "dev@moibittest.local",
+	}
+
+	// Describe every app that has been used with the server
+	server.mu.Lock()
+	appIDs := make([]string, 0, len(server.apps))
+	for appID := range server.apps {
+		if appID != "" {
+			appIDs = append(appIDs, appID)
+		}
+	}
+	server.mu.Unlock()
+
+	sort.Strings(appIDs)
+	for _, appID := range appIDs {
+		app := server.app(appID, r.Header.Get("networkID"))
+		dev.Apps = append(dev.Apps, struct {
+			IsActive  bool `json:"isActive"`
+			IsRemoved bool `json:"isRemoved"`
+
+			AppID   string `json:"appID"`
+			AppName string `json:"appName"`
+
+			Replication    int    `json:"replication"`
+			EncryptionType int    `json:"encryptionType"`
+			EncryptionAlgo string `json:"encryptionAlgo"`
+			RecoveryTime   int64  `json:"recoveryTime"`
+
+			NetworkID   string `json:"networkID"`
+			NetworkName string `json:"networkName"`
+		}{
+			IsActive: app.IsActive, AppID: app.AppID, AppName: app.AppName,
+			Replication: app.Replication, NetworkID: app.NetworkID, NetworkName: app.NetworkName,
+		})
+	}
+
+	dev.NoOfApps = len(dev.Apps)
+	server.respond(w, http.StatusOK, "developer details", dev)
+}
+
+// failure is an error response of the storage
+type failure struct {
+	code    int
+	message string
+}
+
+// fileVersion is a single version of a stored file
+type fileVersion struct {
+	moibit.FileVersionDescriptor
+	data []byte
+}
+
+// entry is a file or directory in the storage
+type entry struct {
+	directory bool
+	versions  []*fileVersion
+}
+
+// latest returns the latest active version of the file, or nil if no version is active
+func (e *entry) latest() *fileVersion {
+	for i := len(e.versions) - 1; i >= 0; i-- {
+		if e.versions[i].Active {
+			return e.versions[i]
+		}
+	}
+
+	return nil
+}
+
+// version returns the version of the file with the given number, or nil if it does not exist
+func (e *entry) version(number int) *fileVersion {
+	for _, version := range e.versions {
+		if version.Version == number {
+			return version
+		}
+	}
+
+	return nil
+}
+
+// storage is the in-memory file system of an app
+type storage struct {
+	entries map[string]*entry
+}
+
+// newStorage returns an empty storage with a root directory
+func newStorage() *storage {
+	return &storage{entries: map[string]*entry{"/": {directory: true}}}
+}
+
+// cleanPath normalizes a path into an absolute, slash separated path
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// exists returns whether a file or directory exists at the given path
+func (store *storage) exists(p string) bool {
+	e, ok := store.entries[p]
+	return ok && (e.directory || e.latest() != nil)
+}
+
+// fileDescriptor returns the FileDescriptor for the given version of the file at the given path.
+// The directory of a file has a trailing slash, while the path is the name of the file.
+func fileDescriptor(p string, version *fileVersion) moibit.FileDescriptor {
+	directory := path.Dir(p)
+	if directory != "/" {
+		directory += "/"
+	}
+
+	return moibit.FileDescriptor{
+		FileVersionDescriptor: version.FileVersionDescriptor,
+		Path:                  path.Base(p),
+		Directory:             directory,
+		NodeAddress:           "moibittest",
+	}
+}
+
+// directoryDescriptor returns the FileDescriptor for the directory at the given path.
+// The directory of a directory is its path without the leading slash.
+func directoryDescriptor(p string) moibit.FileDescriptor {
+	return moibit.FileDescriptor{
+		FileVersionDescriptor: moibit.FileVersionDescriptor{Active: true, Enable: true},
+		IsDirectory:           true,
+		Directory:             strings.TrimPrefix(p, "/"),
+		NodeAddress:           "moibittest",
+	}
+}
+
+// list returns the descriptors of the files and directories directly inside the directory at the given path
+func (store *storage) list(dirpath string) ([]moibit.FileDescriptor, *failure) {
+	if e, ok := store.entries[dirpath]; !ok || !e.directory {
+		return nil, &failure{http.StatusNotFound, fmt.Sprintf("directory '%v' not found", dirpath)}
+	}
+
+	children := make([]string, 0)
+	for p := range store.entries {
+		if p != "/" && path.Dir(p) == dirpath && store.exists(p) {
+			children = append(children, p)
+		}
+	}
+
+	sort.Strings(children)
+
+	files := make([]moibit.FileDescriptor, 0, len(children))
+	for _, child := range children {
+		if e := store.entries[child]; e.directory {
+			files = append(files, directoryDescriptor(child))
+		} else {
+			files = append(files, fileDescriptor(child, e.latest()))
+		}
+	}
+
+	return files, nil
+}
+
+// status returns the descriptor of the file or directory at the given path.
+// An empty descriptor is returned if nothing exists at the path.
+func (store *storage) status(p string) moibit.FileDescriptor {
+	if !store.exists(p) {
+		return moibit.FileDescriptor{}
+	}
+
+	if e := store.entries[p]; e.directory {
+		return directoryDescriptor(p)
+	} else {
+		return fileDescriptor(p, e.latest())
+	}
+}
+
+// versions returns the version descriptors of all the versions of the file at the given path
+func (store *storage) versions(p string) ([]moibit.FileVersionDescriptor, *failure) {
+	e, ok := store.entries[p]
+	if !ok || e.directory {
+		return nil, &failure{http.StatusNotFound, fmt.Sprintf("file '%v' not found", p)}
+	}
+
+	versions := make([]moibit.FileVersionDescriptor, 0, len(e.versions))
+	for _, version := range e.versions {
+		versions = append(versions, version.FileVersionDescriptor)
+	}
+
+	return versions, nil
+}
+
+// read returns the data of the given version of the file at the given path.
+// The latest active version is read if the version is 0.
+func (store *storage) read(p string, number int) ([]byte, *failure) {
+	e, ok := store.entries[p]
+	if !ok || e.directory {
+		return nil, &failure{http.StatusNotFound, fmt.Sprintf("file '%v' not found", p)}
+	}
+
+	version := e.latest()
+	if number != 0 {
+		version = e.version(number)
+	}
+
+	if version == nil || !version.Active {
+		return nil, &failure{http.StatusNotFound, fmt.Sprintf("version %v of file '%v' not found", number, p)}
+	}
+
+	return version.data, nil
+}
+
+// makeDirectory creates a directory at the given path along with any missing parents
+func (store *storage) makeDirectory(dirpath string) *failure {
+	if e, ok := store.entries[dirpath]; ok && (e.directory || e.latest() != nil) {
+		return &failure{http.StatusConflict, fmt.Sprintf("'%v' already exists", dirpath)}
+	}
+
+	return store.makeParents(path.Join(dirpath, "_"))
+}
+
+// makeParents creates all the missing parent directories of the given path
+func (store *storage) makeParents(p string) *failure {
+	missing := make([]string, 0)
+	for parent := path.Dir(p); ; parent = path.Dir(parent) {
+		if e, ok := store.entries[parent]; ok && e.directory {
+			break
+		} else if ok && e.latest() != nil {
+			return &failure{http.StatusConflict, fmt.Sprintf("'%v' is a file", parent)}
+		}
+
+		missing = append(missing, parent)
+	}
+
+	for _, dirpath := range missing {
+		store.entries[dirpath] = &entry{directory: true}
+	}
+
+	return nil
+}
+
+// write stores the given data as a file with the given write request
+func (store *storage) write(request writeRequest, data []byte) (moibit.FileDescriptor, *failure) {
+	p := cleanPath(request.FileName)
+	if p == "/" {
+		return moibit.FileDescriptor{}, &failure{http.StatusBadRequest, "missing file name"}
+	}
+
+	// Check the parent directories of the file
+	if parent, ok := store.entries[path.Dir(p)]; !ok || !parent.directory {
+		if !request.CreateFolders {
+			return moibit.FileDescriptor{}, &failure{http.StatusNotFound, fmt.Sprintf("directory '%v' not found", path.Dir(p))}
+		}
+
+		if err := store.makeParents(p); err != nil {
+			return moibit.FileDescriptor{}, err
+		}
+	}
+
+	e, ok := store.entries[p]
+	if ok && e.directory {
+		return moibit.FileDescriptor{}, &failure{http.StatusConflict, fmt.Sprintf("'%v' is a directory", p)}
+	} else if !ok {
+		e = &entry{}
+		store.entries[p] = e
+	}
+
+	replication := request.Replication
+	if replication == 0 {
+		replication = 1
+	}
+
+	version := &fileVersion{
+		FileVersionDescriptor: moibit.FileVersionDescriptor{
+			Active: true, Enable: true,
+			Hash:        moibit.HashBytes(data, moibit.CIDv0),
+			Version:     1,
+			Replication: replication,
+			FileSize:    len(data),
+			LastUpdated: time.Now().UTC().Format(time.RFC3339),
+		},
+		data: append([]byte(nil), data...),
+	}
+
+	if request.IsProvenance {
+		version.ProvenanceHash = "provenance-" + version.Hash
+	}
+
+	// Keep the previous versions or overwrite the file entirely
+	if request.KeepPrevious && len(e.versions) > 0 {
+		version.Version = e.versions[len(e.versions)-1].Version + 1
+		e.versions = append(e.versions, version)
+	} else {
+		e.versions = []*fileVersion{version}
+	}
+
+	return fileDescriptor(p, version), nil
+}
+
+// remove deactivates the given version of the file at the given path.
+// All the versions of the file are deactivated if the version is 0.
+func (store *storage) remove(p string, number int) *failure {
+	e, ok := store.entries[p]
+	if !ok || (!e.directory && e.latest() == nil) {
+		return &failure{http.StatusNotFound, fmt.Sprintf("file '%v' not found", p)}
+	}
+
+	if e.directory {
+		return &failure{http.StatusBadRequest, fmt.Sprintf("'%v' is a directory", p)}
+	}
+
+	if number == 0 {
+		for _, version := range e.versions {
+			version.Active = false
+		}
+
+		return nil
+	}
+
+	version := e.version(number)
+	if version == nil || !version.Active {
+		return &failure{http.StatusNotFound, fmt.Sprintf("version %v of file '%v' not found", number, p)}
+	}
+
+	version.Active = false
+	return nil
+}
+
+// removeDirectory removes the directory at the given path along with all of its contents
+func (store *storage) removeDirectory(dirpath string) *failure {
+	e, ok := store.entries[dirpath]
+	if !ok || !e.directory {
+		return &failure{http.StatusNotFound, fmt.Sprintf("directory '%v' not found", dirpath)}
+	}
+
+	if dirpath == "/" {
+		return &failure{http.StatusBadRequest, "cannot remove the root directory"}
+	}
+
+	for p := range store.entries {
+		if p == dirpath || strings.HasPrefix(p, dirpath+"/") {
+			delete(store.entries, p)
+		}
+	}
+
+	return nil
+}
+
+// restore reactivates the given version of the file at the given path.
+// The latest version of the file is restored if the version is 0.
+func (store *storage) restore(p string, number int) *failure {
+	e, ok := store.entries[p]
+	if !ok || e.directory || len(e.versions) == 0 {
+		return &failure{http.StatusNotFound, fmt.Sprintf("file '%v' not found", p)}
+	}
+
+	version := e.versions[len(e.versions)-1]
+	if number != 0 {
+		version = e.version(number)
+	}
+
+	if version == nil {
+		return &failure{http.StatusNotFound, fmt.Sprintf("version %v of file '%v' not found", number, p)}
+	}
+
+	if version.Active {
+		return &failure{http.StatusConflict, fmt.Sprintf("version %v of file '%v' is not removed", version.Version, p)}
+	}
+
+	version.Active = true
+	return nil
+}
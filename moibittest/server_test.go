@@ -0,0 +1,324 @@
+package moibittest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// newClient starts a Server and returns it with a Client authenticated against it.
+// The server is closed when the test finishes.
+func newClient(t *testing.T, opts ...moibit.ClientOption) (*moibittest.Server, *moibit.Client) {
+	t.Helper()
+
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := server.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("client could not be created: %v", err)
+	}
+
+	return server, client
+}
+
+// mustWrite writes the given text to a file with the client or fails the test
+func mustWrite(t *testing.T, client *moibit.Client, name, text string, opts ...moibit.WriteOption) moibit.FileDescriptor {
+	t.Helper()
+
+	file, err := client.WriteFile([]byte(text), name, opts...)
+	if err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", name, err)
+	}
+
+	return file
+}
+
+// mustRead reads the given version of a file with the client and compares it with the given text
+func mustRead(t *testing.T, client *moibit.Client, name string, version int, text string) {
+	t.Helper()
+
+	data, err := client.ReadFile(name, version)
+	if err != nil {
+		t.Fatalf("ReadFile(%v, %v) failed: %v", name, version, err)
+	}
+
+	if string(data) != text {
+		t.Errorf("ReadFile(%v, %v) = %q, want %q", name, version, data, text)
+	}
+}
+
+func TestListAndStatus(t *testing.T) {
+	_, client := newClient(t)
+
+	file := mustWrite(t, client, "/a.txt", "alpha")
+	mustWrite(t, client, "/dir/b.txt", "bravo")
+
+	if file.FullPath() != "/a.txt" || file.Version != 1 || file.FileSize != 5 || file.Hash != moibit.HashBytes([]byte("alpha"), moibit.CIDv0) {
+		t.Errorf("WriteFile = %+v, want version 1 of /a.txt with 5 bytes", file)
+	}
+
+	files, err := client.ListFiles("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0].FullPath() != "/a.txt" || files[0].IsDirectory || files[1].FullPath() != "/dir" || !files[1].IsDirectory {
+		t.Errorf("ListFiles(/) = %v, want /a.txt and the directory /dir", files)
+	}
+
+	files, err = client.ListFiles("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].FullPath() != "/dir/b.txt" {
+		t.Errorf("ListFiles(/dir) = %v, want /dir/b.txt", files)
+	}
+
+	if _, err := client.ListFiles("/missing"); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ListFiles(/missing) = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	status, err := client.FileStatus("/dir/b.txt")
+	if err != nil || !status.Exists() || status.IsDirectory || status.FileSize != 5 {
+		t.Errorf("FileStatus(/dir/b.txt) = %+v, %v, want the file", status, err)
+	}
+
+	status, err = client.FileStatus("/dir")
+	if err != nil || !status.IsDirectory || status.FullPath() != "/dir" {
+		t.Errorf("FileStatus(/dir) = %+v, %v, want the directory", status, err)
+	}
+
+	status, err = client.FileStatus("/missing.txt")
+	if err != nil || status.Exists() {
+		t.Errorf("FileStatus(/missing.txt) = %+v, %v, want an empty descriptor", status, err)
+	}
+}
+
+func TestKeepPrevious(t *testing.T) {
+	_, client := newClient(t)
+
+	mustWrite(t, client, "/notes.txt", "first")
+	second := mustWrite(t, client, "/notes.txt", "second", moibit.KeepPrevious())
+	if second.Version != 2 {
+		t.Errorf("version %v written with KeepPrevious, want 2", second.Version)
+	}
+
+	mustRead(t, client, "/notes.txt", 0, "second")
+	mustRead(t, client, "/notes.txt", 1, "first")
+	mustRead(t, client, "/notes.txt", 2, "second")
+
+	versions, err := client.FileVersions("/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 || !versions[0].Active || !versions[1].Active {
+		t.Errorf("FileVersions = %+v, want the active versions 1 and 2", versions)
+	}
+
+	// A write without KeepPrevious replaces every version
+	third := mustWrite(t, client, "/notes.txt", "third")
+	if third.Version != 1 {
+		t.Errorf("version %v written without KeepPrevious, want 1", third.Version)
+	}
+
+	if versions, _ := client.FileVersions("/notes.txt"); len(versions) != 1 {
+		t.Errorf("%v versions after an overwrite, want 1", len(versions))
+	}
+
+	if _, err := client.ReadFile("/notes.txt", 2); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile of a replaced version = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	if _, err := client.FileVersions("/missing.txt"); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("FileVersions(/missing.txt) = %v, want %v", err, moibit.ErrNotFound)
+	}
+}
+
+func TestRemoveAndRestore(t *testing.T) {
+	_, client := newClient(t)
+
+	mustWrite(t, client, "/doc.txt", "v1")
+	mustWrite(t, client, "/doc.txt", "v2", moibit.KeepPrevious())
+
+	// Removing the latest version falls back to the previous one
+	if err := client.RemoveFile("/doc.txt", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRead(t, client, "/doc.txt", 0, "v1")
+	if _, err := client.ReadFile("/doc.txt", 2); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile of a removed version = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	if err := client.RemoveFile("/doc.txt", 2); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("RemoveFile of a removed version = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	// Restoring an active version is a conflict
+	if err := client.RemoveFile("/doc.txt", 1, moibit.PerformRestore()); !errors.Is(err, moibit.ErrAlreadyExists) {
+		t.Errorf("restore of an active version = %v, want %v", err, moibit.ErrAlreadyExists)
+	}
+
+	if err := client.RemoveFile("/doc.txt", 2, moibit.PerformRestore()); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRead(t, client, "/doc.txt", 0, "v2")
+
+	// Removing version 0 removes the file entirely
+	if err := client.RemoveFile("/doc.txt", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ReadFile("/doc.txt", 0); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile of a removed file = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	if status, _ := client.FileStatus("/doc.txt"); status.Exists() {
+		t.Errorf("FileStatus of a removed file = %+v, want an empty descriptor", status)
+	}
+
+	if files, _ := client.ListFiles("/"); len(files) != 0 {
+		t.Errorf("ListFiles(/) = %v, want no files", files)
+	}
+
+	// Restoring version 0 restores the latest version
+	if err := client.RemoveFile("/doc.txt", 0, moibit.PerformRestore()); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRead(t, client, "/doc.txt", 0, "v2")
+}
+
+func TestDirectories(t *testing.T) {
+	_, client := newClient(t)
+
+	if err := client.MakeDirectory("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.MakeDirectory("/a/b"); !errors.Is(err, moibit.ErrAlreadyExists) {
+		t.Errorf("MakeDirectory of an existing directory = %v, want %v", err, moibit.ErrAlreadyExists)
+	}
+
+	for _, dirpath := range []string{"/a", "/a/b"} {
+		if status, err := client.FileStatus(dirpath); err != nil || !status.IsDirectory {
+			t.Errorf("FileStatus(%v) = %+v, %v, want a directory", dirpath, status, err)
+		}
+	}
+
+	// Files in missing directories are only written if the folders can be created
+	if _, err := client.WriteFile([]byte("x"), "/c/file.txt", moibit.CreateOnlyFile()); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("WriteFile into a missing directory = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	mustWrite(t, client, "/a/b/file.txt", "x", moibit.CreateOnlyFile())
+	mustWrite(t, client, "/c/d/file.txt", "y")
+
+	if _, err := client.WriteFile([]byte("z"), "/a/b", moibit.KeepPrevious()); !errors.Is(err, moibit.ErrAlreadyExists) {
+		t.Errorf("WriteFile over a directory = %v, want %v", err, moibit.ErrAlreadyExists)
+	}
+
+	if err := client.RemoveFile("/a", 0); err == nil {
+		t.Error("RemoveFile of a directory without RemoveDirectory succeeded")
+	}
+
+	// Removing a directory removes its contents
+	if err := client.RemoveFile("/a", 0, moibit.RemoveDirectory()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ReadFile("/a/b/file.txt", 0); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile in a removed directory = %v, want %v", err, moibit.ErrNotFound)
+	}
+
+	files, err := client.ListFiles("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].FullPath() != "/c" {
+		t.Errorf("ListFiles(/) = %v, want /c", files)
+	}
+}
+
+func TestAppIsolation(t *testing.T) {
+	server, first := newClient(t, moibit.AppID("first"))
+
+	second, err := server.NewClient(moibit.AppID("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustWrite(t, first, "/shared.txt", "first")
+
+	if _, err := second.ReadFile("/shared.txt", 0); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile from another app = %v, want %v", err, moibit.ErrNotFound)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server := moibittest.NewServer()
+	defer server.Close()
+
+	if _, err := moibit.NewClient("wrong", server.Nonce, server.ClientOptions()...); !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("NewClient with a wrong signature = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Errorf("ListFiles with the accepted credentials = %v", err)
+	}
+}
+
+func TestWriteTextToFileDeformed(t *testing.T) {
+	server, client := newClient(t)
+
+	// The raw response carries the descriptors as strings of JSON arrays
+	request, _ := http.NewRequest("POST", server.URL+"/writetexttofile", strings.NewReader(`{"fileName": "/raw.txt", "text": "raw", "createFolders": true}`))
+	request.Header.Set("signature", server.Signature)
+	request.Header.Set("nonce", server.Nonce)
+	request.Header.Set("developerKey", server.DeveloperKey)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var body struct {
+		Data []string `json:"data"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("response data is not an array of strings: %v", err)
+	}
+
+	var descriptors []moibit.FileDescriptor
+	if len(body.Data) != 1 || json.Unmarshal([]byte(body.Data[0]), &descriptors) != nil || len(descriptors) != 1 || descriptors[0].Path != "raw.txt" {
+		t.Fatalf("response data = %q, want a string with a JSON array of the descriptor of raw.txt", body.Data)
+	}
+
+	// The client decodes the deformed response into the descriptor of the file
+	file, err := client.WriteFile([]byte("text"), "/text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Calls("/writetexttofile") != 2 || file.FullPath() != "/text.txt" || file.Hash != moibit.HashBytes([]byte("text"), moibit.CIDv0) {
+		t.Errorf("WriteFile = %+v, want the descriptor of /text.txt from /writetexttofile", file)
+	}
+}
@@ -0,0 +1,187 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultProfile is the name of the profile used when no profile is selected
+const DefaultProfile = "default"
+
+// Environment variables that select the configuration file and profile and override the values of profiles
+const (
+	EnvConfig    = "MOIBIT_CONFIG"
+	EnvProfile   = "MOIBIT_PROFILE"
+	EnvSignature = "MOIBIT_SIGNATURE"
+	EnvNonce     = "MOIBIT_NONCE"
+	EnvAppID     = "MOIBIT_APP_ID"
+	EnvNetworkID = "MOIBIT_NETWORK_ID"
+	EnvBaseURL   = "MOIBIT_BASE_URL"
+)
+
+// Profile is a named set of credentials and settings for a Client
+type Profile struct {
+	Signature string `toml:"signature"`
+	Nonce     string `toml:"nonce"`
+	AppID     string `toml:"app_id"`
+	NetworkID string `toml:"network_id"`
+	BaseURL   string `toml:"base_url"`
+}
+
+// Config is the configuration file of named profiles. It is encoded as TOML:
+//
+//	default_profile = "production"
+//
+//	[profiles.production]
+//	signature = "0x..."
+//	nonce = "..."
+//	app_id = "..."
+//	network_id = "..."
+//	base_url = "https://api.moinet.io/moibit/v1"
+type Config struct {
+	DefaultProfile string             `toml:"default_profile"`
+	Profiles       map[string]Profile `toml:"profiles"`
+}
+
+// DefaultConfigPath returns the path of the configuration file, which is the value of MOIBIT_CONFIG
+// if it is set or moibit/config.toml in the user configuration directory (e.g. ~/.config/moibit/config.toml).
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "moibit", "config.toml"), nil
+}
+
+// LoadConfig reads the configuration file at the given path
+func LoadConfig(path string) (*Config, error) {
+	config := new(Config)
+	if _, err := toml.DecodeFile(path, config); err != nil {
+		return nil, fmt.Errorf("config '%v' could not be decoded: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Profile returns the profile of the given name from the Config.
+// If the name is empty, the default profile of the Config is returned, or DefaultProfile if it is not set.
+func (config *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = config.DefaultProfile
+	}
+
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile '%v' not found", name)
+	}
+
+	return profile, nil
+}
+
+// LoadProfile returns the profile of the given name from the configuration file at DefaultConfigPath, with the
+// values overridden by the environment variables MOIBIT_SIGNATURE, MOIBIT_NONCE, MOIBIT_APP_ID, MOIBIT_NETWORK_ID
+// and MOIBIT_BASE_URL. If the name is empty, the profile is selected by MOIBIT_PROFILE or the default profile of
+// the file. A missing configuration file is only an error if a profile is selected explicitly, so a profile can
+// be given entirely with environment variables.
+func LoadProfile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return Profile{}, fmt.Errorf("config path could not be determined: %w", err)
+	}
+
+	var profile Profile
+
+	config, err := LoadConfig(path)
+	switch {
+	case err == nil:
+		// Only a profile that is selected explicitly or by the file must exist
+		if profile, err = config.Profile(name); err != nil && (name != "" || config.DefaultProfile != "") {
+			return Profile{}, err
+		}
+
+	case errors.Is(err, fs.ErrNotExist) && name == "":
+		// Fall back to the environment variables
+
+	default:
+		return Profile{}, err
+	}
+
+	profile.applyEnv()
+	return profile, nil
+}
+
+// applyEnv overrides the values of the Profile with the environment variables that are set
+func (profile *Profile) applyEnv() {
+	overrides := map[string]*string{
+		EnvSignature: &profile.Signature,
+		EnvNonce:     &profile.Nonce,
+		EnvAppID:     &profile.AppID,
+		EnvNetworkID: &profile.NetworkID,
+		EnvBaseURL:   &profile.BaseURL,
+	}
+
+	for variable, value := range overrides {
+		if env, ok := os.LookupEnv(variable); ok {
+			*value = env
+		}
+	}
+}
+
+// ClientOptions returns the ClientOption that set the App ID, Network ID and Base URL of the Profile.
+// Values that are not set in the Profile are left to their defaults.
+func (profile Profile) ClientOptions() []ClientOption {
+	opts := make([]ClientOption, 0, 3)
+	if profile.AppID != "" {
+		opts = append(opts, AppID(profile.AppID))
+	}
+
+	if profile.NetworkID != "" {
+		opts = append(opts, NetworkID(profile.NetworkID))
+	}
+
+	if profile.BaseURL != "" {
+		opts = append(opts, BaseURL(profile.BaseURL))
+	}
+
+	return opts
+}
+
+// NewClientFromProfile creates a new MOIBit API Client from the profile of the given name, loaded with LoadProfile.
+// The given ClientOption are applied after the options of the profile, so they take precedence.
+func NewClientFromProfile(name string, opts ...ClientOption) (*Client, error) {
+	return NewClientFromProfileContext(context.Background(), name, opts...)
+}
+
+// NewClientFromProfileContext creates a new MOIBit API Client from the profile of the given name.
+// It behaves like NewClientFromProfile but uses the given context for the authentication request.
+func NewClientFromProfileContext(ctx context.Context, name string, opts ...ClientOption) (*Client, error) {
+	profile, err := LoadProfile(name)
+	if err != nil {
+		return nil, fmt.Errorf("profile could not be loaded: %w", err)
+	}
+
+	if profile.Signature == "" || profile.Nonce == "" {
+		return nil, fmt.Errorf("profile has no signature or nonce")
+	}
+
+	return NewClientContext(ctx, profile.Signature, profile.Nonce, append(profile.ClientOptions(), opts...)...)
+}
@@ -0,0 +1,213 @@
+package moibit_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// testConfig is a configuration file with a default profile and two named profiles
+const testConfig = `
+default_profile = "staging"
+
+[profiles.default]
+signature = "0xdefault"
+nonce = "default-nonce"
+
+[profiles.staging]
+signature = "0xstaging"
+nonce = "staging-nonce"
+app_id = "staging-app"
+network_id = "staging-network"
+base_url = "https://staging.example.com/moibit/v1"
+
+[profiles.production]
+signature = "0xproduction"
+nonce = "production-nonce"
+app_id = "production-app"
+`
+
+// setConfig writes the given configuration file and points MOIBIT_CONFIG to it, with the other
+// MOIBIT_* environment variables unset. An empty configuration is not written, so the file is missing.
+func setConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	for _, variable := range []string{moibit.EnvProfile, moibit.EnvSignature, moibit.EnvNonce, moibit.EnvAppID, moibit.EnvNetworkID, moibit.EnvBaseURL} {
+		t.Setenv(variable, "")
+		os.Unsetenv(variable)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv(moibit.EnvConfig, path)
+
+	if config != "" {
+		if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	setConfig(t, testConfig)
+
+	// The default profile of the file is used without a name
+	profile, err := moibit.LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := moibit.Profile{
+		Signature: "0xstaging",
+		Nonce:     "staging-nonce",
+		AppID:     "staging-app",
+		NetworkID: "staging-network",
+		BaseURL:   "https://staging.example.com/moibit/v1",
+	}
+
+	if profile != want {
+		t.Errorf("LoadProfile(\"\") = %+v, want %+v", profile, want)
+	}
+
+	// A profile selected by name or by MOIBIT_PROFILE, with the name taking precedence
+	if profile, err := moibit.LoadProfile("production"); err != nil || profile.AppID != "production-app" || profile.BaseURL != "" {
+		t.Errorf("LoadProfile(production) = %+v, %v, want the production profile", profile, err)
+	}
+
+	t.Setenv(moibit.EnvProfile, "default")
+	if profile, err := moibit.LoadProfile(""); err != nil || profile.Signature != "0xdefault" {
+		t.Errorf("LoadProfile with MOIBIT_PROFILE = %+v, %v, want the default profile", profile, err)
+	}
+
+	if profile, err := moibit.LoadProfile("production"); err != nil || profile.Signature != "0xproduction" {
+		t.Errorf("LoadProfile(production) with MOIBIT_PROFILE = %+v, %v, want the production profile", profile, err)
+	}
+}
+
+func TestLoadProfileDefault(t *testing.T) {
+	// Without a default profile in the file, the profile named default is used
+	setConfig(t, `
+[profiles.default]
+signature = "0xdefault"
+nonce = "default-nonce"
+`)
+
+	if profile, err := moibit.LoadProfile(""); err != nil || profile.Signature != "0xdefault" {
+		t.Errorf("LoadProfile(\"\") = %+v, %v, want the default profile", profile, err)
+	}
+
+	// Without either, the profile is given by the environment variables
+	setConfig(t, `
+[profiles.other]
+signature = "0xother"
+`)
+
+	t.Setenv(moibit.EnvSignature, "0xenv")
+	if profile, err := moibit.LoadProfile(""); err != nil || profile != (moibit.Profile{Signature: "0xenv"}) {
+		t.Errorf("LoadProfile(\"\") without a default profile = %+v, %v, want the environment", profile, err)
+	}
+}
+
+func TestLoadProfileEnv(t *testing.T) {
+	setConfig(t, testConfig)
+
+	t.Setenv(moibit.EnvSignature, "0xenv")
+	t.Setenv(moibit.EnvAppID, "env-app")
+	t.Setenv(moibit.EnvBaseURL, "http://localhost:8080")
+
+	profile, err := moibit.LoadProfile("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the variables that are set override the file
+	want := moibit.Profile{
+		Signature: "0xenv",
+		Nonce:     "staging-nonce",
+		AppID:     "env-app",
+		NetworkID: "staging-network",
+		BaseURL:   "http://localhost:8080",
+	}
+
+	if profile != want {
+		t.Errorf("LoadProfile(staging) = %+v, want %+v", profile, want)
+	}
+
+	// The profile is given entirely by the environment variables if the file is missing
+	setConfig(t, "")
+	t.Setenv(moibit.EnvSignature, "0xenv")
+	t.Setenv(moibit.EnvNonce, "env-nonce")
+
+	if profile, err := moibit.LoadProfile(""); err != nil || profile != (moibit.Profile{Signature: "0xenv", Nonce: "env-nonce"}) {
+		t.Errorf("LoadProfile(\"\") without a file = %+v, %v, want the environment", profile, err)
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+	}{
+		{"missing profile", testConfig, "development"},
+		{"missing default profile", `default_profile = "development"`, ""},
+		{"missing file", "", "production"},
+		{"invalid file", `[profiles.default`, ""},
+		{"invalid value", "[profiles.default]\nsignature = 1", ""},
+	}
+
+	for _, test := range tests {
+		setConfig(t, test.config)
+		if profile, err := moibit.LoadProfile(test.profile); err == nil {
+			t.Errorf("LoadProfile(%q) with a %v = %+v, want an error", test.profile, test.name, profile)
+		}
+	}
+
+	// MOIBIT_PROFILE selects a profile explicitly
+	setConfig(t, testConfig)
+	t.Setenv(moibit.EnvProfile, "development")
+	if profile, err := moibit.LoadProfile(""); err == nil {
+		t.Errorf("LoadProfile with a missing MOIBIT_PROFILE = %+v, want an error", profile)
+	}
+}
+
+func TestNewClientFromProfile(t *testing.T) {
+	server := moibittest.NewServer()
+	t.Cleanup(server.Close)
+
+	setConfig(t, `
+[profiles.test]
+signature = "`+server.Signature+`"
+nonce = "`+server.Nonce+`"
+app_id = "profile-app"
+base_url = "`+server.URL+`"
+`)
+
+	client, err := moibit.NewClientFromProfile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if app, err := client.AppDetails(); err != nil || app.AppID != "profile-app" {
+		t.Errorf("AppDetails() = %+v, %v, want the app of the profile", app, err)
+	}
+
+	// The given options take precedence over the profile
+	client, err = moibit.NewClientFromProfile("test", moibit.AppID("option-app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if app, err := client.AppDetails(); err != nil || app.AppID != "option-app" {
+		t.Errorf("AppDetails() = %+v, %v, want the app of the option", app, err)
+	}
+
+	t.Setenv(moibit.EnvNonce, "")
+	if _, err := moibit.NewClientFromProfile("test"); err == nil {
+		t.Error("NewClientFromProfile without a nonce succeeded, want an error")
+	}
+}
@@ -0,0 +1,200 @@
+package moibit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OnReauthenticate returns a ClientOption that sets a hook to notify the application whenever the Client
+// authenticates again because MOIBit rejected its credentials. The hook is called with the error of the
+// re-authentication, which is nil if it succeeded and the rejected request is replayed. The hook may make
+// requests with the Client, which are performed before the rejected request is replayed.
+func OnReauthenticate(hook func(err error)) ClientOption {
+	return func(client *Client) error {
+		client.onReauth = hook
+		return nil
+	}
+}
+
+// Reauthenticate authenticates the client with MOIBit again and replaces its public key.
+// If the client was created with NewClientWithSigner, a fresh nonce is signed with its Signer,
+// otherwise the credentials of the client are authenticated again. The credentials of the
+// client are only replaced once they are authenticated.
+func (client *Client) Reauthenticate(ctx context.Context) error {
+	client.reauth.Lock()
+	defer client.reauth.Unlock()
+
+	return client.authenticate(ctx)
+}
+
+// authenticate authenticates the client with MOIBit again and replaces its credentials.
+// The reauth lock of the client must be held by the caller.
+func (client *Client) authenticate(ctx context.Context) error {
+	client.mu.RLock()
+	signature, nonce := client.signature, client.nonce
+	client.mu.RUnlock()
+
+	// Sign a fresh nonce if the client has a signer
+	if client.signer != nil {
+		var err error
+		if signature, nonce, err = client.signer.Sign(ctx); err != nil {
+			return fmt.Errorf("credentials could not be signed: %w", err)
+		}
+	}
+
+	// Authenticate the credentials with a copy of the client
+	candidate := client.credentialsCopy()
+	candidate.signature, candidate.nonce = signature, nonce
+
+	pubkey, err := AuthenticateContext(ctx, candidate)
+	if err != nil {
+		return fmt.Errorf("user could not be authenticated: %w", err)
+	}
+
+	client.setCredentials(signature, nonce, pubkey)
+	return nil
+}
+
+// do performs the given HTTP request with the client, retrying it with send. A client created with LazyAuth is
+// authenticated before its first request. If MOIBit rejects the credentials of the request, the client authenticates
+// again and the request is replayed once with the new credentials. Requests with a body that cannot be regenerated
+// are not replayed and their rejected response is returned as it is.
+func (client *Client) do(request *http.Request, idempotent bool) (*http.Response, error) {
+	// Authenticate the client on its first request if its authentication was deferred
+	if !client.isAuthenticated() {
+		if err := client.authenticateOnce(request.Context()); err != nil {
+			return nil, err
+		}
+
+		client.setHeaders(request)
+	}
+
+	generation := client.credentialsGeneration()
+
+	response, err := client.send(request, idempotent)
+	if err != nil {
+		return nil, err
+	}
+
+	response, rejected := rejectedCredentials(response)
+	if !rejected || (request.Body != nil && request.Body != http.NoBody && request.GetBody == nil) {
+		return response, nil
+	}
+
+	// Discard the rejected response
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	ctx := request.Context()
+	if err := client.reauthenticate(ctx, generation); err != nil {
+		return nil, fmt.Errorf("re-authentication failed: %w", err)
+	}
+
+	// Regenerate the request with the new credentials
+	replay := request.Clone(ctx)
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		replay.Body = body
+	}
+
+	client.setHeaders(replay)
+	return client.send(replay, idempotent)
+}
+
+// authenticateOnce authenticates a client created with LazyAuth, unless a concurrent request authenticated it first
+func (client *Client) authenticateOnce(ctx context.Context) error {
+	client.reauth.Lock()
+	defer client.reauth.Unlock()
+
+	if client.isAuthenticated() {
+		return nil
+	}
+
+	return client.authenticate(ctx)
+}
+
+// reauthenticate authenticates the client again after a request with the credentials of the given
+// generation was rejected and notifies the hook of the client. Concurrent rejections are coalesced,
+// the client does not authenticate again if the credentials were replaced after the given generation.
+// The hook is called after the reauth lock is released, so it can make requests with the client.
+func (client *Client) reauthenticate(ctx context.Context, generation uint64) error {
+	client.reauth.Lock()
+	if client.credentialsGeneration() != generation {
+		client.reauth.Unlock()
+		return nil
+	}
+
+	err := client.authenticate(ctx)
+	client.reauth.Unlock()
+
+	if client.onReauth != nil {
+		client.onReauth(err)
+	}
+
+	return err
+}
+
+// maxMetadataSniff is the maximum number of leading bytes of a JSON response that are read to find its metadata
+const maxMetadataSniff = 4096
+
+// rejectedCredentials returns whether the given response rejects the credentials of its request, either with the
+// HTTP status 401 or with the code 401 in the metadata of a JSON response. The metadata is decoded as the body is
+// read, stopping as soon as its code is found or after maxMetadataSniff bytes. The bytes read are replayed to the
+// caller, so the returned response must be used in place of the given response.
+func rejectedCredentials(response *http.Response) (*http.Response, bool) {
+	if response.StatusCode == http.StatusUnauthorized {
+		return response, true
+	}
+
+	if !strings.Contains(response.Header.Get("Content-Type"), "json") {
+		return response, false
+	}
+
+	sniffed := new(bytes.Buffer)
+	code := metadataCode(io.TeeReader(io.LimitReader(response.Body, maxMetadataSniff), sniffed))
+
+	response.Body = readCloser{io.MultiReader(sniffed, response.Body), response.Body}
+	return response, code == http.StatusUnauthorized
+}
+
+// metadataCode returns the code in the metadata of the JSON response read from the given reader.
+// The reader is only read until the metadata is decoded. Returns 0 if the metadata is not found.
+func metadataCode(reader io.Reader) int {
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+
+		if key == "meta" {
+			meta := new(responseMetadata)
+			if err := decoder.Decode(meta); err != nil {
+				return 0
+			}
+
+			return meta.StatusCode
+		}
+
+		// Skip the values of other fields
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return 0
+		}
+	}
+
+	return 0
+}
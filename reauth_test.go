@@ -0,0 +1,190 @@
+package moibit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// reauthCounter returns a ClientOption that counts the re-authentications of a Client, and a function that returns them
+func reauthCounter() (moibit.ClientOption, func() []error) {
+	var mu sync.Mutex
+	var reauthentications []error
+
+	hook := moibit.OnReauthenticate(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		reauthentications = append(reauthentications, err)
+	})
+
+	return hook, func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]error(nil), reauthentications...)
+	}
+}
+
+func TestReauthMetaCode(t *testing.T) {
+	hook, reauthentications := reauthCounter()
+	server, client := newTestClient(t, hook)
+
+	// The listing is larger than the leading bytes that are sniffed for the metadata
+	files := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("/dir/file-%03d.txt", i)] = "data"
+	}
+
+	writeFiles(t, client, files)
+
+	// The HTTP status remains 200 while the metadata rejects the credentials
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", MetaCode: 401})
+
+	listed, err := client.ListFiles("/dir")
+	if err != nil || len(listed) != len(files) {
+		t.Fatalf("ListFiles with meta code 401 = %v files, %v, want it replayed", len(listed), err)
+	}
+
+	if calls := server.Calls("/listfiles"); calls != 2 {
+		t.Errorf("%v calls to /listfiles, want 2", calls)
+	}
+
+	if errs := reauthentications(); len(errs) != 1 || errs[0] != nil {
+		t.Errorf("OnReauthenticate called with %v, want one successful re-authentication", errs)
+	}
+
+	// Responses that do not reject the credentials are read in full after the metadata is sniffed
+	if listed, err := client.ListFiles("/dir"); err != nil || len(listed) != len(files) {
+		t.Errorf("ListFiles = %v files, %v, want %v files", len(listed), err, len(files))
+	}
+}
+
+func TestReauthNotReplayedWithoutGetBody(t *testing.T) {
+	hook, reauthentications := reauthCounter()
+	server, client := newTestClient(t, hook)
+
+	// The body of a streamed write has no GetBody, so the rejected request cannot be replayed
+	server.Inject(moibittest.Fault{Endpoint: "/writefile", MetaCode: 401})
+
+	_, err := client.WriteFrom(context.Background(), strings.NewReader("data"), "/stream.txt")
+	if !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("WriteFrom = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+
+	if calls := server.Calls("/writefile"); calls != 1 {
+		t.Errorf("%v calls to /writefile, want 1", calls)
+	}
+
+	if calls := server.Calls("/user/auth"); calls != 1 {
+		t.Errorf("%v calls to /user/auth, want only the initial authentication", calls)
+	}
+
+	if errs := reauthentications(); len(errs) != 0 {
+		t.Errorf("OnReauthenticate called with %v, want no re-authentication", errs)
+	}
+
+	// A request with a body that can be regenerated is replayed
+	server.Inject(moibittest.Fault{Endpoint: "/writefile", ExpireAuth: true})
+	if _, err := client.WriteFile([]byte("data"), "/file.bin", moibit.BinaryUpload()); err != nil {
+		t.Errorf("WriteFile with an expired session = %v, want it replayed", err)
+	}
+
+	if calls := server.Calls("/writefile"); calls != 3 {
+		t.Errorf("%v calls to /writefile, want 3", calls)
+	}
+}
+
+func TestReauthConcurrent(t *testing.T) {
+	hook, reauthentications := reauthCounter()
+	server, client := newTestClient(t, hook)
+	writeFiles(t, client, map[string]string{"/a.txt": "alpha"})
+
+	// Every concurrent request is rejected before the slow re-authentication completes
+	const requests = 8
+	server.ExpireAuth()
+	server.Inject(moibittest.Fault{Endpoint: "/user/auth", Latency: 200 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	errs := make([]error, requests)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = client.FileStatus("/a.txt")
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("FileStatus %v = %v, want it replayed", i, err)
+		}
+	}
+
+	// The rejections of the same credentials are coalesced into one re-authentication
+	if calls := server.Calls("/user/auth"); calls != 2 {
+		t.Errorf("%v calls to /user/auth, want 2", calls)
+	}
+
+	if calls := server.Calls("/filestatus"); calls != 2*requests {
+		t.Errorf("%v calls to /filestatus, want %v", calls, 2*requests)
+	}
+
+	if errs := reauthentications(); len(errs) != 1 || errs[0] != nil {
+		t.Errorf("OnReauthenticate called with %v, want one successful re-authentication", errs)
+	}
+}
+
+func TestReauthHookUsesClient(t *testing.T) {
+	var client *moibit.Client
+	var server *moibittest.Server
+
+	// The hook makes requests with the client, which must not wait on the re-authentication
+	var hookErr error
+	hook := moibit.OnReauthenticate(func(err error) {
+		if err != nil {
+			hookErr = err
+			return
+		}
+
+		if err := client.Reauthenticate(context.Background()); err != nil {
+			hookErr = err
+			return
+		}
+
+		_, hookErr = client.ListFiles("/")
+	})
+
+	server, client = newTestClient(t, hook)
+	writeFiles(t, client, map[string]string{"/a.txt": "alpha"})
+
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", MetaCode: 401})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ListFiles("/")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ListFiles with a hook using the client = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListFiles with a hook using the client did not return")
+	}
+
+	if hookErr != nil {
+		t.Errorf("request of the hook = %v", hookErr)
+	}
+}
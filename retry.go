@@ -0,0 +1,189 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how a Client retries requests that fail transiently.
+// Requests to idempotent endpoints are always retried with the policy, while
+// WriteFile, RemoveFile and MakeDirectory requests are only retried if they opt
+// in with the RetryWrite, RetryRemove and RetryMakeDir options respectively.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts for a request, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it is doubled for each subsequent retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts of a request, including delays requested with Retry-After.
+	MaxDelay time.Duration
+	// Jitter is the fraction (between 0 and 1) of each delay that is randomized.
+	Jitter float64
+	// Retryable classifies whether an attempt should be retried based on its response or error.
+	// DefaultRetryable is used if it is nil.
+	Retryable func(*http.Response, error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with 3 attempts, exponential backoff
+// starting from 200ms and capped at 5s with 50% jitter and the DefaultRetryable classifier.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.5,
+		Retryable:   DefaultRetryable,
+	}
+}
+
+// Retry returns a ClientOption that can be used to set the RetryPolicy for a Client.
+// By default, a Client does not retry any requests.
+func Retry(policy RetryPolicy) ClientOption {
+	return func(client *Client) error {
+		client.retry = policy
+		return nil
+	}
+}
+
+// DefaultRetryable is the default retry classifier of a RetryPolicy.
+// Returns true for transport errors (except context cancellation) and for
+// responses with the status 429 (Too Many Requests), 500, 502, 503 or 504.
+func DefaultRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryable returns whether an attempt with the given response and error should be retried
+func (policy RetryPolicy) retryable(response *http.Response, err error) bool {
+	if policy.Retryable == nil {
+		return DefaultRetryable(response, err)
+	}
+
+	return policy.Retryable(response, err)
+}
+
+// jitterFloat64 returns the random fraction of a delay that is subtracted by the jitter of a RetryPolicy
+var jitterFloat64 = rand.Float64
+
+// backoff returns the delay before the next attempt of a request after the given number of attempts.
+// If the response carries a Retry-After header, the delay specified by it is used instead.
+// Both delays are capped by the MaxDelay of the policy, so a server cannot stall a request indefinitely.
+func (policy RetryPolicy) backoff(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if delay, ok := retryAfter(response.Header.Get("Retry-After")); ok {
+			if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+				delay = policy.MaxDelay
+			}
+
+			return delay
+		}
+	}
+
+	// Double the base delay for every attempt, up to the max delay
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	// Randomize a fraction of the delay
+	if policy.Jitter > 0 && delay > 0 {
+		jitter := policy.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+
+		delay -= time.Duration(jitterFloat64() * jitter * float64(delay))
+	}
+
+	return delay
+}
+
+// retryAfter parses the value of a Retry-After header which
+// is either a number of seconds or an HTTP date.
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// send performs the given HTTP request with the client.
+// If the request is idempotent, it is retried with the RetryPolicy of the client.
+// Retried requests are regenerated with the GetBody function of the request, requests
+// with a body that cannot be regenerated are never retried. Waiting between attempts
+// is aborted if the context of the request is cancelled.
+func (client *Client) send(request *http.Request, idempotent bool) (*http.Response, error) {
+	attempts := client.retry.MaxAttempts
+	if !idempotent || attempts < 1 || (request.Body != nil && request.Body != http.NoBody && request.GetBody == nil) {
+		attempts = 1
+	}
+
+	ctx := request.Context()
+	for attempt := 1; ; attempt++ {
+		// Regenerate the request for every retry
+		current := request
+		if attempt > 1 {
+			current = request.Clone(ctx)
+			if request.GetBody != nil {
+				body, err := request.GetBody()
+				if err != nil {
+					return nil, err
+				}
+
+				current.Body = body
+			}
+		}
+
+		response, err := client.c.Do(current)
+		if attempt >= attempts || !client.retry.retryable(response, err) {
+			return response, err
+		}
+
+		delay := client.retry.backoff(attempt, response)
+
+		// Discard the response of the failed attempt
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
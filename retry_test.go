@@ -0,0 +1,183 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withJitterSeed replaces the jitter source with a seeded one for the duration of the test
+func withJitterSeed(t *testing.T, seed int64) {
+	previous := jitterFloat64
+	jitterFloat64 = rand.New(rand.NewSource(seed)).Float64
+	t.Cleanup(func() { jitterFloat64 = previous })
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt    int
+		retryAfter string
+		want       time.Duration
+	}{
+		{1, "", 100 * time.Millisecond},
+		{2, "", 200 * time.Millisecond},
+		{3, "", 400 * time.Millisecond},
+		{4, "", 800 * time.Millisecond},
+		{5, "", time.Second},
+		{50, "", time.Second},
+		{1, "0", 0},
+		{1, "1", time.Second},
+		{3, "3600", time.Second},
+		{1, "invalid", 100 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		response := &http.Response{Header: make(http.Header)}
+		if test.retryAfter != "" {
+			response.Header.Set("Retry-After", test.retryAfter)
+		}
+
+		if got := policy.backoff(test.attempt, response); got != test.want {
+			t.Errorf("backoff(%v, Retry-After %q) = %v, want %v", test.attempt, test.retryAfter, got, test.want)
+		}
+	}
+}
+
+func TestBackoffUncapped(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Millisecond}
+	if got := policy.backoff(11, nil); got != 1024*time.Millisecond {
+		t.Errorf("backoff(11) = %v, want %v", got, 1024*time.Millisecond)
+	}
+
+	response := &http.Response{Header: http.Header{"Retry-After": []string{"3600"}}}
+	if got := policy.backoff(1, response); got != time.Hour {
+		t.Errorf("backoff with Retry-After and no MaxDelay = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestBackoffRetryAfterDate(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Minute}
+	response := &http.Response{Header: make(http.Header)}
+
+	response.Header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	if got := policy.backoff(1, response); got != 0 {
+		t.Errorf("backoff with past Retry-After date = %v, want 0", got)
+	}
+
+	response.Header.Set("Retry-After", time.Now().Add(24*time.Hour).UTC().Format(http.TimeFormat))
+	if got := policy.backoff(1, response); got != time.Minute {
+		t.Errorf("backoff with distant Retry-After date = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+
+	withJitterSeed(t, 42)
+	expected := rand.New(rand.NewSource(42))
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		base := (RetryPolicy{BaseDelay: policy.BaseDelay, MaxDelay: policy.MaxDelay}).backoff(attempt, nil)
+		want := base - time.Duration(expected.Float64()*0.5*float64(base))
+
+		got := policy.backoff(attempt, nil)
+		if got != want {
+			t.Errorf("backoff(%v) = %v, want %v", attempt, got, want)
+		}
+
+		if got < base/2 || got > base {
+			t.Errorf("backoff(%v) = %v, want within [%v, %v]", attempt, got, base/2, base)
+		}
+	}
+}
+
+func TestBackoffJitterClamped(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, Jitter: 5}
+
+	withJitterSeed(t, 7)
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1, nil); got < 0 || got > policy.BaseDelay {
+			t.Fatalf("backoff with jitter above 1 = %v, want within [0, %v]", got, policy.BaseDelay)
+		}
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusOK: false, http.StatusBadRequest: false, http.StatusUnauthorized: false, http.StatusNotFound: false,
+		http.StatusTooManyRequests: true, http.StatusInternalServerError: true, http.StatusBadGateway: true,
+		http.StatusServiceUnavailable: true, http.StatusGatewayTimeout: true, http.StatusNotImplemented: false,
+	} {
+		if got := DefaultRetryable(&http.Response{StatusCode: status}, nil); got != want {
+			t.Errorf("DefaultRetryable(%v) = %v, want %v", status, got, want)
+		}
+	}
+
+	if !DefaultRetryable(nil, errors.New("connection reset")) {
+		t.Error("transport errors are not retryable")
+	}
+
+	if DefaultRetryable(nil, context.Canceled) || DefaultRetryable(nil, context.DeadlineExceeded) {
+		t.Error("context errors are retryable")
+	}
+}
+
+func TestSendRetryAfterCapped(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", strconv.Itoa(3600))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := defaultClient("", "")
+	client.retry = RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	start := time.Now()
+	response, err := client.send(request, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("status %v after %v calls, want 200 after 2 calls", response.StatusCode, calls)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry waited %v, want the Retry-After capped by MaxDelay", elapsed)
+	}
+}
+
+func TestSendContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := defaultClient("", "")
+	client.retry = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if _, err := client.send(request, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("send = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,283 @@
+package moibit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+// Signer produces the signature and nonce pair that authenticates a developer with MOIBit.
+// A Client created with NewClientWithSigner uses its Signer to sign a fresh nonce every time it authenticates.
+type Signer interface {
+	// Sign returns a signature of a freshly generated nonce and the nonce
+	Sign(ctx context.Context) (signature, nonce string, err error)
+}
+
+// KeySigner is a Signer backed by the secp256k1 private key of the Ethereum account of a developer.
+// The nonce is signed as an Ethereum personal message (personal_sign), which is the signature expected by MOIBit.
+type KeySigner struct {
+	key     *secp256k1.PrivateKey
+	address string
+}
+
+// NewKeySigner returns a KeySigner for the given hex encoded secp256k1 private key, with or without the "0x" prefix
+func NewKeySigner(privateKey string) (*KeySigner, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(privateKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("private key decode failed: %w", err)
+	}
+
+	return newKeySigner(raw)
+}
+
+// newKeySigner returns a KeySigner for the given raw secp256k1 private key
+func newKeySigner(raw []byte) (*KeySigner, error) {
+	if len(raw) != secp256k1.PrivKeyBytesLen {
+		return nil, fmt.Errorf("invalid private key size: %v bytes, expected %v", len(raw), secp256k1.PrivKeyBytesLen)
+	}
+
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(raw); overflow || scalar.IsZero() {
+		return nil, fmt.Errorf("invalid private key")
+	}
+
+	key := secp256k1.NewPrivateKey(&scalar)
+	return &KeySigner{key: key, address: publicKeyAddress(key.PubKey())}, nil
+}
+
+// NewKeystoreSigner returns a KeySigner for the private key in the given Ethereum keystore v3 JSON file,
+// decrypted with the given password. Keystores with the scrypt and pbkdf2 key derivations are supported.
+func NewKeystoreSigner(keystore []byte, password string) (*KeySigner, error) {
+	raw, err := decryptKeystore(keystore, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKeySigner(raw)
+}
+
+// Address returns the Ethereum address of the KeySigner, which is the developer key returned by MOIBit
+func (signer *KeySigner) Address() string {
+	return signer.address
+}
+
+// Sign implements the Signer interface for KeySigner.
+// The nonce is a random 32 character hex string.
+func (signer *KeySigner) Sign(_ context.Context) (string, string, error) {
+	entropy := make([]byte, 16)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", "", fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	nonce := hex.EncodeToString(entropy)
+	return signer.SignMessage(nonce), nonce, nil
+}
+
+// SignMessage returns the hex encoded Ethereum personal signature (r || s || v) of the given message
+func (signer *KeySigner) SignMessage(message string) string {
+	compact := ecdsa.SignCompact(signer.key, personalHash(message), false)
+
+	// The compact signature is v || r || s with v = 27 + recovery code
+	signature := append(compact[1:], compact[0])
+	return "0x" + hex.EncodeToString(signature)
+}
+
+// RecoverAddress returns the Ethereum address of the account that produced the given
+// hex encoded personal signature (r || s || v) of the given message.
+func RecoverAddress(message, signature string) (string, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(raw) != 65 {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	// Accept both 0/1 and 27/28 recovery codes
+	v := raw[64]
+	if v < 27 {
+		v += 27
+	}
+
+	compact := append([]byte{v}, raw[:64]...)
+	pubkey, _, err := ecdsa.RecoverCompact(compact, personalHash(message))
+	if err != nil {
+		return "", fmt.Errorf("signature could not be recovered: %w", err)
+	}
+
+	return publicKeyAddress(pubkey), nil
+}
+
+// personalHash returns the keccak256 hash of the given message with the Ethereum personal message prefix
+func personalHash(message string) []byte {
+	return keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+}
+
+// publicKeyAddress returns the hex encoded Ethereum address of the given public key
+func publicKeyAddress(pubkey *secp256k1.PublicKey) string {
+	return "0x" + hex.EncodeToString(keccak256(pubkey.SerializeUncompressed()[1:])[12:])
+}
+
+// keccak256 returns the legacy keccak256 hash of the given data used by Ethereum
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, chunk := range data {
+		hash.Write(chunk)
+	}
+
+	return hash.Sum(nil)
+}
+
+// keystoreV3 is the JSON format of an Ethereum keystore v3 file
+type keystoreV3 struct {
+	Version int `json:"version"`
+	Crypto  struct {
+		Cipher       string `json:"cipher"`
+		CipherText   string `json:"ciphertext"`
+		CipherParams struct {
+			IV string `json:"iv"`
+		} `json:"cipherparams"`
+		KDF       string          `json:"kdf"`
+		KDFParams json.RawMessage `json:"kdfparams"`
+		MAC       string          `json:"mac"`
+	} `json:"crypto"`
+}
+
+// decryptKeystore returns the raw private key in the given Ethereum keystore v3 JSON file
+func decryptKeystore(keystore []byte, password string) ([]byte, error) {
+	file := new(keystoreV3)
+	if err := json.Unmarshal(keystore, file); err != nil {
+		return nil, fmt.Errorf("keystore decode failed: %w", err)
+	}
+
+	if file.Version != 3 {
+		return nil, fmt.Errorf("unsupported keystore version: %v", file.Version)
+	}
+
+	if file.Crypto.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported keystore cipher: %v", file.Crypto.Cipher)
+	}
+
+	// Derive the key from the password
+	var derived []byte
+	switch file.Crypto.KDF {
+	case "scrypt":
+		params := new(struct {
+			N     int    `json:"n"`
+			R     int    `json:"r"`
+			P     int    `json:"p"`
+			DKLen int    `json:"dklen"`
+			Salt  string `json:"salt"`
+		})
+
+		if err := json.Unmarshal(file.Crypto.KDFParams, params); err != nil {
+			return nil, fmt.Errorf("keystore kdf params decode failed: %w", err)
+		}
+
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("keystore salt decode failed: %w", err)
+		}
+
+		if derived, err = scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen); err != nil {
+			return nil, fmt.Errorf("keystore key derivation failed: %w", err)
+		}
+
+	case "pbkdf2":
+		params := new(struct {
+			C     int    `json:"c"`
+			DKLen int    `json:"dklen"`
+			PRF   string `json:"prf"`
+			Salt  string `json:"salt"`
+		})
+
+		if err := json.Unmarshal(file.Crypto.KDFParams, params); err != nil {
+			return nil, fmt.Errorf("keystore kdf params decode failed: %w", err)
+		}
+
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported keystore prf: %v", params.PRF)
+		}
+
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("keystore salt decode failed: %w", err)
+		}
+
+		derived = pbkdf2.Key([]byte(password), salt, params.C, params.DKLen, sha256.New)
+
+	default:
+		return nil, fmt.Errorf("unsupported keystore kdf: %v", file.Crypto.KDF)
+	}
+
+	if len(derived) < 32 {
+		return nil, fmt.Errorf("invalid keystore derived key size: %v bytes", len(derived))
+	}
+
+	ciphertext, err := hex.DecodeString(file.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore ciphertext decode failed: %w", err)
+	}
+
+	iv, err := hex.DecodeString(file.Crypto.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid keystore iv")
+	}
+
+	mac, err := hex.DecodeString(file.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("keystore mac decode failed: %w", err)
+	}
+
+	// Verify the password with the MAC before decrypting the key, in constant time
+	if subtle.ConstantTimeCompare(keccak256(derived[16:32], ciphertext), mac) != 1 {
+		return nil, fmt.Errorf("keystore could not be decrypted: wrong password")
+	}
+
+	block, err := aes.NewCipher(derived[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(key, ciphertext)
+	return key, nil
+}
+
+// NewClientWithSigner creates a new MOIBit API Client that authenticates with the signature and nonce produced
+// by the given Signer. Unlike NewClient, the Client signs a fresh nonce whenever it authenticates again.
+// Accepts a variadic number of ClientOption arguments, like NewClient.
+func NewClientWithSigner(signer Signer, opts ...ClientOption) (*Client, error) {
+	return NewClientWithSignerContext(context.Background(), signer, opts...)
+}
+
+// NewClientWithSignerContext creates a new MOIBit API Client that authenticates with the given Signer.
+// It behaves like NewClientWithSigner but uses the given context for signing and the authentication request.
+func NewClientWithSignerContext(ctx context.Context, signer Signer, opts ...ClientOption) (*Client, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("nil signer")
+	}
+
+	signature, nonce, err := signer.Sign(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("credentials could not be signed: %w", err)
+	}
+
+	// Set the signer before the options, so that a deferred authentication signs a fresh nonce
+	opts = append([]ClientOption{func(client *Client) error {
+		client.signer = signer
+		return nil
+	}}, opts...)
+
+	return NewClientContext(ctx, signature, nonce, opts...)
+}
@@ -0,0 +1,145 @@
+package moibit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// The keystore vectors are the test vectors of the Web3 Secret Storage Definition, encrypting
+// keystoreKey with the password "testpassword" using each key derivation function
+const (
+	keystoreKey     = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	keystoreAddress = "0x008aeeda4d805471df9b2a5b0f38a0c3bcba786b"
+
+	scryptKeystore = `{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"83dbcc02d8ccb40e466191a123791e0e"},` +
+		`"ciphertext":"d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c","kdf":"scrypt",` +
+		`"kdfparams":{"dklen":32,"n":262144,"r":1,"p":8,"salt":"ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},` +
+		`"mac":"2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`
+
+	pbkdf2Keystore = `{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"6087dab2f9fdbbfaddc31a909735c1e6"},` +
+		`"ciphertext":"5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46","kdf":"pbkdf2",` +
+		`"kdfparams":{"c":262144,"dklen":32,"prf":"hmac-sha256","salt":"ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},` +
+		`"mac":"517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`
+)
+
+func TestKeystoreSigner(t *testing.T) {
+	expected, err := moibit.NewKeySigner(keystoreKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected.Address() != keystoreAddress {
+		t.Fatalf("Address() of the keystore key = %v, want %v", expected.Address(), keystoreAddress)
+	}
+
+	for kdf, keystore := range map[string]string{"scrypt": scryptKeystore, "pbkdf2": pbkdf2Keystore} {
+		signer, err := moibit.NewKeystoreSigner([]byte(keystore), "testpassword")
+		if err != nil {
+			t.Errorf("NewKeystoreSigner(%v) failed: %v", kdf, err)
+			continue
+		}
+
+		// The decrypted key produces the same signatures as the key itself
+		if signer.Address() != keystoreAddress || signer.SignMessage("nonce") != expected.SignMessage("nonce") {
+			t.Errorf("NewKeystoreSigner(%v) has address %v, want the key of %v", kdf, signer.Address(), keystoreAddress)
+		}
+	}
+}
+
+func TestKeystoreSignerErrors(t *testing.T) {
+	if _, err := moibit.NewKeystoreSigner([]byte(pbkdf2Keystore), "wrongpassword"); err == nil || !strings.Contains(err.Error(), "wrong password") {
+		t.Errorf("NewKeystoreSigner with a wrong password = %v, want a wrong password error", err)
+	}
+
+	tests := map[string]string{
+		"invalid json":       `{"crypto": `,
+		"version":            strings.Replace(pbkdf2Keystore, `"version":3`, `"version":1`, 1),
+		"cipher":             strings.Replace(pbkdf2Keystore, "aes-128-ctr", "aes-128-cbc", 1),
+		"kdf":                strings.Replace(pbkdf2Keystore, `"kdf":"pbkdf2"`, `"kdf":"argon2"`, 1),
+		"prf":                strings.Replace(pbkdf2Keystore, "hmac-sha256", "hmac-sha512", 1),
+		"mac":                strings.Replace(pbkdf2Keystore, `"mac":"517e`, `"mac":"617e`, 1),
+		"ciphertext":         strings.Replace(pbkdf2Keystore, `"ciphertext":"5318`, `"ciphertext":"6318`, 1),
+		"truncated mac":      strings.Replace(pbkdf2Keystore, `"mac":"517ead`, `"mac":"`, 1),
+		"invalid iv":         strings.Replace(pbkdf2Keystore, `"iv":"6087`, `"iv":"`, 1),
+		"short derived key":  strings.Replace(pbkdf2Keystore, `"dklen":32`, `"dklen":16`, 1),
+		"invalid salt":       strings.Replace(pbkdf2Keystore, `"salt":"ae`, `"salt":"zz`, 1),
+		"invalid kdf params": strings.Replace(pbkdf2Keystore, `"c":262144`, `"c":"262144"`, 1),
+	}
+
+	for name, keystore := range tests {
+		if _, err := moibit.NewKeystoreSigner([]byte(keystore), "testpassword"); err == nil {
+			t.Errorf("NewKeystoreSigner with an unexpected %v succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	// The personal_sign signature of "Some data" from the web3.js documentation of eth.accounts.sign
+	signer, err := moibit.NewKeySigner("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		address   = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+		signature = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+	)
+
+	if signer.Address() != address {
+		t.Errorf("Address() = %v, want %v", signer.Address(), address)
+	}
+
+	if signed := signer.SignMessage("Some data"); signed != signature {
+		t.Errorf("SignMessage(\"Some data\") = %v, want %v", signed, signature)
+	}
+
+	if recovered, err := moibit.RecoverAddress("Some data", signature); err != nil || recovered != address {
+		t.Errorf("RecoverAddress() = %v, %v, want %v", recovered, err, address)
+	}
+
+	// Recovery codes of 0 and 1 are accepted like 27 and 28
+	if recovered, err := moibit.RecoverAddress("Some data", signature[:130]+"01"); err != nil || recovered != address {
+		t.Errorf("RecoverAddress() with recovery code 1 = %v, %v, want %v", recovered, err, address)
+	}
+
+	// A signature of another message recovers another address
+	if recovered, err := moibit.RecoverAddress("Other data", signature); err == nil && recovered == address {
+		t.Errorf("RecoverAddress() of another message = %v, want another address", recovered)
+	}
+
+	for _, invalid := range []string{"", "0x1234", "0x" + strings.Repeat("zz", 65)} {
+		if _, err := moibit.RecoverAddress("Some data", invalid); err == nil {
+			t.Errorf("RecoverAddress(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestKeySignerRoundTrip(t *testing.T) {
+	signer, err := moibit.NewKeySigner(keystoreKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every authentication signs a fresh nonce
+	signature, nonce, err := signer.Sign(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, other, _ := signer.Sign(context.Background()); other == nonce {
+		t.Errorf("Sign() returned the nonce %v twice", nonce)
+	}
+
+	if recovered, err := moibit.RecoverAddress(nonce, signature); err != nil || recovered != signer.Address() {
+		t.Errorf("RecoverAddress() of the signed nonce = %v, %v, want %v", recovered, err, signer.Address())
+	}
+
+	for _, invalid := range []string{"", "0x1234", strings.Repeat("0", 64)} {
+		if _, err := moibit.NewKeySigner(invalid); err == nil {
+			t.Errorf("NewKeySigner(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncDirection represents an enumeration for the directions in which Sync transfers files
+type SyncDirection int
+
+const (
+	// SyncPush makes the remote directory match the local directory
+	SyncPush SyncDirection = iota
+
+	// SyncPull makes the local directory match the remote directory
+	SyncPull
+
+	// SyncBidirectional transfers new and changed files in both directions,
+	// resolving files that differ on both sides with the ConflictPolicy.
+	SyncBidirectional
+)
+
+// String implements the Stringer interface for SyncDirection
+func (direction SyncDirection) String() string {
+	switch direction {
+	case SyncPush:
+		return "push"
+	case SyncPull:
+		return "pull"
+	case SyncBidirectional:
+		return "bidirectional"
+	default:
+		return fmt.Sprintf("SyncDirection(%d)", int(direction))
+	}
+}
+
+// ConflictPolicy represents an enumeration for the resolutions of files that
+// differ between the local and remote directory during a bidirectional Sync.
+type ConflictPolicy int
+
+const (
+	// PreferNewer resolves a conflict with the file that was modified last
+	PreferNewer ConflictPolicy = iota
+
+	// PreferLocal resolves a conflict by uploading the local file
+	PreferLocal
+
+	// PreferRemote resolves a conflict by downloading the remote file
+	PreferRemote
+
+	// SkipConflicts leaves conflicting files untouched, they are reported in the plan
+	SkipConflicts
+)
+
+// SyncOperation represents an enumeration for the operations of a SyncAction
+type SyncOperation int
+
+const (
+	// SyncUpload uploads the local file to MOIBit
+	SyncUpload SyncOperation = iota
+
+	// SyncDownload downloads the remote file from MOIBit
+	SyncDownload
+
+	// SyncDeleteLocal deletes the local file
+	SyncDeleteLocal
+
+	// SyncDeleteRemote removes the remote file from MOIBit
+	SyncDeleteRemote
+
+	// SyncConflict reports a conflicting file that is left untouched
+	SyncConflict
+)
+
+// String implements the Stringer interface for SyncOperation
+func (operation SyncOperation) String() string {
+	switch operation {
+	case SyncUpload:
+		return "upload"
+	case SyncDownload:
+		return "download"
+	case SyncDeleteLocal:
+		return "delete-local"
+	case SyncDeleteRemote:
+		return "delete-remote"
+	case SyncConflict:
+		return "conflict"
+	default:
+		return fmt.Sprintf("SyncOperation(%d)", int(operation))
+	}
+}
+
+// SyncAction is a single operation of a SyncPlan
+type SyncAction struct {
+	// Operation to perform for the file
+	Operation SyncOperation
+	// Path of the file relative to the synchronized directories
+	Path string
+	// Path of the file on the local file system
+	LocalPath string
+	// Path of the file on MOIBit
+	RemotePath string
+	// Reason for the operation
+	Reason string
+	// Error that caused the operation to fail, if any. Set when the plan is applied.
+	Err error
+
+	remote FileDescriptor
+}
+
+// SyncPlan is the set of operations required to synchronize a local directory and a directory on MOIBit.
+// A plan is computed by PlanSync and can be reviewed (it prints as a readable listing) before it is applied.
+type SyncPlan struct {
+	LocalDir  string
+	RemoteDir string
+	Direction SyncDirection
+	Actions   []SyncAction
+
+	config *syncConfig
+}
+
+// String implements the Stringer interface for SyncPlan.
+// Each action of the plan is printed on its own line.
+func (plan *SyncPlan) String() string {
+	builder := new(strings.Builder)
+	fmt.Fprintf(builder, "sync %v: %v <-> %v (%d actions)\n", plan.Direction, plan.LocalDir, plan.RemoteDir, len(plan.Actions))
+
+	for _, action := range plan.Actions {
+		fmt.Fprintf(builder, "  %-13v %v (%v)", action.Operation, action.Path, action.Reason)
+		if action.Err != nil {
+			fmt.Fprintf(builder, ": %v", action.Err)
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// Err returns an error joining the errors of all failed actions of the plan, or nil if none failed
+func (plan *SyncPlan) Err() error {
+	errs := make([]error, 0)
+	for _, action := range plan.Actions {
+		if action.Err != nil {
+			errs = append(errs, fmt.Errorf("%v %v: %w", action.Operation, action.Path, action.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SyncOption is an option for the Sync and PlanSync methods of Client.
+type SyncOption func(*syncConfig) error
+
+// syncConfig is the configuration of a sync
+type syncConfig struct {
+	conflicts ConflictPolicy
+	delete    bool
+	dryRun    bool
+	settle    bool
+	transfer  *transferConfig
+
+	// Whether files are encrypted or compressed on the client, in which
+	// case their remote size and hash describe the transformed data
+	transformed bool
+	// Whether downloaded files are decompressed if they were compressed on the client
+	decompress bool
+}
+
+// WithConflictPolicy returns a SyncOption that can be used to set the ConflictPolicy of a bidirectional sync.
+// The default policy is PreferNewer.
+func WithConflictPolicy(policy ConflictPolicy) SyncOption {
+	return func(config *syncConfig) error {
+		config.conflicts = policy
+		return nil
+	}
+}
+
+// DeleteExtraneous returns a SyncOption that can be used to delete the files from the destination of a
+// push or pull sync that do not exist in its source. Bidirectional syncs never delete files.
+func DeleteExtraneous() SyncOption {
+	return func(config *syncConfig) error {
+		config.delete = true
+		return nil
+	}
+}
+
+// DryRun returns a SyncOption that can be used to compute the plan of a Sync without applying it
+func DryRun() SyncOption {
+	return func(config *syncConfig) error {
+		config.dryRun = true
+		return nil
+	}
+}
+
+// SettleModTimes returns a SyncOption that can be used to set the modification time of uploaded local files to the
+// time their upload was last updated on MOIBit. Files that are compared by their modification time (such as files
+// encrypted or compressed on the client) are otherwise newer on MOIBit after they are uploaded, so the next
+// bidirectional sync or pull downloads them again. The local files are modified by the sync with this option.
+func SettleModTimes() SyncOption {
+	return func(config *syncConfig) error {
+		config.settle = true
+		return nil
+	}
+}
+
+// WithTransferOptions returns a SyncOption that can be used to set the TransferOption for the files
+// transferred by a sync, such as the concurrency and the WriteOption of uploaded files.
+func WithTransferOptions(opts ...TransferOption) SyncOption {
+	return func(config *syncConfig) error {
+		for _, opt := range opts {
+			if err := opt(config.transfer); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// localFile describes a file in the local directory of a sync
+type localFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// Sync synchronizes the given local directory with the given directory on MOIBit in the given direction.
+// It computes the plan with PlanSync and applies it with ApplySync, unless the DryRun option is given.
+// Returns the plan with the error of every failed action, and an error joining them.
+func (client *Client) Sync(ctx context.Context, localDir, remoteDir string, direction SyncDirection, opts ...SyncOption) (*SyncPlan, error) {
+	plan, err := client.PlanSync(ctx, localDir, remoteDir, direction, opts...)
+	if err != nil || plan.config.dryRun {
+		return plan, err
+	}
+
+	return plan, client.ApplySync(ctx, plan)
+}
+
+// PlanSync computes the plan to synchronize the given local directory with the given directory on MOIBit
+// in the given direction, without modifying either. Files are compared by their size and content hash, falling
+// back to their modification time if the hash cannot be compared: a push only considers files modified locally
+// after the remote one, a pull only files modified remotely after the local one and a bidirectional sync both.
+func (client *Client) PlanSync(ctx context.Context, localDir, remoteDir string, direction SyncDirection, opts ...SyncOption) (*SyncPlan, error) {
+	config := &syncConfig{conflicts: PreferNewer, transfer: defaultTransferConfig()}
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, fmt.Errorf("sync option could not be applied: %w", err)
+		}
+	}
+
+	remoteDir = path.Join("/", remoteDir)
+	config.transformed = client.keys != nil || config.transfer.compressed()
+	config.decompress = decompresses(config.transfer.readOpts)
+
+	localFiles, err := listLocalFiles(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("local listing failed: %w", err)
+	}
+
+	remoteFiles, err := client.listRemoteFiles(ctx, remoteDir)
+	if err != nil {
+		return nil, fmt.Errorf("remote listing failed: %w", err)
+	}
+
+	// Collect the union of the relative paths on both sides
+	paths := make([]string, 0, len(localFiles)+len(remoteFiles))
+	for relative := range localFiles {
+		paths = append(paths, relative)
+	}
+
+	for relative := range remoteFiles {
+		if _, ok := localFiles[relative]; !ok {
+			paths = append(paths, relative)
+		}
+	}
+
+	sort.Strings(paths)
+
+	plan := &SyncPlan{LocalDir: localDir, RemoteDir: remoteDir, Direction: direction, config: config}
+	for _, relative := range paths {
+		local, hasLocal := localFiles[relative]
+		remote, hasRemote := remoteFiles[relative]
+
+		action := SyncAction{
+			Path:       relative,
+			LocalPath:  filepath.Join(localDir, filepath.FromSlash(relative)),
+			RemotePath: path.Join(remoteDir, relative),
+			remote:     remote,
+		}
+
+		// Compare the files that exist on both sides
+		var reason string
+		var changed bool
+		if hasLocal && hasRemote {
+			if reason, changed, err = client.compareSynced(ctx, config, local, remote, action.RemotePath, direction); err != nil {
+				return nil, fmt.Errorf("file '%v' could not be compared: %w", relative, err)
+			}
+		}
+
+		if operation, reason, ok := config.decide(direction, local, remote, hasLocal, hasRemote, reason, changed); ok {
+			action.Operation, action.Reason = operation, reason
+			plan.Actions = append(plan.Actions, action)
+		}
+	}
+
+	return plan, nil
+}
+
+// compareSynced returns whether the given local and remote files of a sync differ and the reason for it, with compareFiles.
+// Files compressed on the client are decompressed when they are downloaded, so the size and hash of a compressed remote
+// file describe the compressed data. Remote files that differ are checked for the compression header when downloads are
+// decompressed, and compressed files are compared with compareModified instead.
+func (client *Client) compareSynced(ctx context.Context, config *syncConfig, local localFile, remote FileDescriptor, remotepath string, direction SyncDirection) (string, bool, error) {
+	reason, changed := compareFiles(local, remote, direction, config.transformed)
+	if !changed || config.transformed || !config.decompress || remote.FileSize < compressionHeaderSize {
+		return reason, changed, nil
+	}
+
+	compressed, err := client.isCompressedFile(ctx, remotepath)
+	if err != nil || !compressed {
+		return reason, changed, err
+	}
+
+	reason, changed = compareModified(local, remote, direction)
+	return reason, changed, nil
+}
+
+// decide returns the operation for a file with the given local and remote state
+// and the result of their comparison, if the file exists on both sides.
+// Returns false if the file requires no operation.
+func (config *syncConfig) decide(direction SyncDirection, local localFile, remote FileDescriptor, hasLocal, hasRemote bool, reason string, changed bool) (SyncOperation, string, bool) {
+	switch {
+	case hasLocal && !hasRemote:
+		if direction == SyncPull {
+			return SyncDeleteLocal, "missing remotely", config.delete
+		}
+
+		return SyncUpload, "missing remotely", true
+
+	case !hasLocal && hasRemote:
+		if direction == SyncPush {
+			return SyncDeleteRemote, "missing locally", config.delete
+		}
+
+		return SyncDownload, "missing locally", true
+	}
+
+	if !changed {
+		return 0, "", false
+	}
+
+	switch direction {
+	case SyncPush:
+		return SyncUpload, reason, true
+	case SyncPull:
+		return SyncDownload, reason, true
+	}
+
+	// Resolve the conflict of a bidirectional sync
+	switch config.conflicts {
+	case PreferLocal:
+		return SyncUpload, reason + ", local preferred", true
+	case PreferRemote:
+		return SyncDownload, reason + ", remote preferred", true
+	case SkipConflicts:
+		return SyncConflict, reason, true
+	}
+
+	if local.info.ModTime().Truncate(time.Second).After(parseTimestamp(remote.LastUpdated)) {
+		return SyncUpload, reason + ", local is newer", true
+	}
+
+	return SyncDownload, reason + ", remote is newer", true
+}
+
+// compareFiles returns whether the given local and remote files differ and the reason for it.
+// Files of the same size are compared by their content hash if the remote hash is a CID, falling back
+// to compareModified for the direction if it is not or the local file cannot be hashed.
+// Files encrypted or compressed on the client are only compared with compareModified.
+func compareFiles(local localFile, remote FileDescriptor, direction SyncDirection, transformed bool) (string, bool) {
+	if transformed {
+		return compareModified(local, remote, direction)
+	}
+
+	if local.info.Size() != int64(remote.FileSize) {
+		return fmt.Sprintf("size differs: %v local, %v remote", local.info.Size(), remote.FileSize), true
+	}
+
+	if version, ok := CIDVersionOf(remote.Hash); ok {
+		if hash, err := HashFile(local.path, version); err == nil {
+			if hash != remote.Hash {
+				return "content differs", true
+			}
+
+			return "", false
+		}
+	}
+
+	return compareModified(local, remote, direction)
+}
+
+// compareModified returns whether the given local and remote files differ by their modification time and the reason
+// for it. Only a newer local file is considered for a push and only a newer remote file is considered for a pull.
+// Uploads are always newer than their local file, unless ApplySync sets the time of uploaded local files to the time
+// of their remote file with settleUpload (with SettleModTimes), just like downloads take the time of their remote file.
+func compareModified(local localFile, remote FileDescriptor, direction SyncDirection) (string, bool) {
+	updated := parseTimestamp(remote.LastUpdated)
+	if updated.IsZero() {
+		return "", false
+	}
+
+	// Timestamps on MOIBit may only have a precision of seconds
+	modified, updated := local.info.ModTime().Truncate(time.Second), updated.Truncate(time.Second)
+
+	switch {
+	case direction != SyncPull && modified.After(updated):
+		return "modified locally", true
+	case direction != SyncPush && updated.After(modified):
+		return "modified remotely", true
+	default:
+		return "", false
+	}
+}
+
+// listLocalFiles returns the regular files in the tree rooted at the given local directory by their
+// slash separated path relative to it. A missing directory is treated as an empty directory.
+func listLocalFiles(localDir string) (map[string]localFile, error) {
+	files := make(map[string]localFile)
+
+	err := filepath.WalkDir(localDir, func(localpath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if localpath == localDir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relative, err := filepath.Rel(localDir, localpath)
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relative)] = localFile{path: localpath, info: info}
+		return nil
+	})
+
+	return files, err
+}
+
+// listRemoteFiles returns the files in the tree rooted at the given directory on MOIBit by their
+// path relative to it. A missing directory is treated as an empty directory.
+func (client *Client) listRemoteFiles(ctx context.Context, remoteDir string) (map[string]FileDescriptor, error) {
+	files := make(map[string]FileDescriptor)
+
+	err := client.Walk(ctx, remoteDir, func(remotepath string, file FileDescriptor, err error) error {
+		if err != nil {
+			if remotepath == remoteDir && (errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrNotFound)) {
+				return fs.SkipAll
+			}
+
+			return err
+		}
+
+		if file.IsDirectory {
+			return nil
+		}
+
+		relative, ok := relativePath(remoteDir, remotepath)
+		if !ok {
+			return fmt.Errorf("remote path '%v' escapes the remote directory", remotepath)
+		}
+
+		files[relative] = file
+		return nil
+	}, OnlyFiles())
+
+	return files, err
+}
+
+// ApplySync applies the actions of the given plan, transferring files with a bounded pool of workers.
+// The error of every failed action is recorded in the plan, and an error joining them is returned.
+func (client *Client) ApplySync(ctx context.Context, plan *SyncPlan) error {
+	config := plan.config
+	if config == nil {
+		config = &syncConfig{transfer: defaultTransferConfig()}
+	}
+
+	parallel(config.transfer.concurrency, len(plan.Actions), func(i int) {
+		action := &plan.Actions[i]
+		if action.Err = ctx.Err(); action.Err != nil {
+			return
+		}
+
+		switch action.Operation {
+		case SyncUpload:
+			var file FileDescriptor
+			if file, _, action.Err = client.uploadLocalFile(ctx, action.LocalPath, action.RemotePath, config.transfer.writeOpts); action.Err == nil && config.settle {
+				action.Err = client.settleUpload(ctx, action.LocalPath, action.RemotePath, file)
+			}
+		case SyncDownload:
+			_, action.Err = client.downloadRemoteFile(ctx, action.RemotePath, action.LocalPath, 0, parseTimestamp(action.remote.LastUpdated), config.transfer.readOpts)
+		case SyncDeleteLocal:
+			action.Err = os.Remove(action.LocalPath)
+		case SyncDeleteRemote:
+			action.Err = client.RemoveFileContext(ctx, action.RemotePath, 0)
+		}
+	})
+
+	return plan.Err()
+}
+
+// settleUpload sets the modification time of the given uploaded local file to the time its upload was last updated
+// on MOIBit, so that the upload is not mistaken for a remote modification when the files are compared by their
+// modification time in the next sync. The time is resolved with FileStatus if the upload response does not carry it.
+func (client *Client) settleUpload(ctx context.Context, localpath, remotepath string, file FileDescriptor) error {
+	updated := parseTimestamp(file.LastUpdated)
+	if updated.IsZero() {
+		status, err := client.FileStatusContext(ctx, remotepath)
+		if err != nil {
+			return fmt.Errorf("uploaded file could not be described: %w", err)
+		}
+
+		if updated = parseTimestamp(status.LastUpdated); updated.IsZero() {
+			return nil
+		}
+	}
+
+	return os.Chtimes(localpath, updated, updated)
+}
@@ -0,0 +1,60 @@
+package moibit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompareFilesFallback(t *testing.T) {
+	localpath := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(localpath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	newer, older := remoteTime.Add(time.Hour), remoteTime.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		modified    time.Time
+		direction   SyncDirection
+		transformed bool
+		hash        string
+		changed     bool
+		reason      string
+	}{
+		{"push local newer", newer, SyncPush, false, "not-a-cid", true, "modified locally"},
+		{"push remote newer", older, SyncPush, false, "not-a-cid", false, ""},
+		{"pull local newer", newer, SyncPull, false, "not-a-cid", false, ""},
+		{"pull remote newer", older, SyncPull, false, "not-a-cid", true, "modified remotely"},
+		{"bidirectional local newer", newer, SyncBidirectional, false, "not-a-cid", true, "modified locally"},
+		{"bidirectional remote newer", older, SyncBidirectional, false, "not-a-cid", true, "modified remotely"},
+		{"same time", remoteTime, SyncBidirectional, false, "not-a-cid", false, ""},
+		{"transformed pull remote newer", older, SyncPull, true, HashBytes([]byte("data"), CIDv0), true, "modified remotely"},
+		{"transformed push remote newer", older, SyncPush, true, HashBytes([]byte("data"), CIDv0), false, ""},
+		{"equal hash ignores time", newer, SyncPush, false, HashBytes([]byte("data"), CIDv0), false, ""},
+		{"different hash ignores time", older, SyncPush, false, HashBytes([]byte("diff"), CIDv0), true, "content differs"},
+	}
+
+	for _, test := range tests {
+		if err := os.Chtimes(localpath, test.modified, test.modified); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(localpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		remote := FileDescriptor{FileVersionDescriptor: FileVersionDescriptor{
+			Hash: test.hash, FileSize: 4, LastUpdated: remoteTime.Format(time.RFC3339),
+		}}
+
+		reason, changed := compareFiles(localFile{path: localpath, info: info}, remote, test.direction, test.transformed)
+		if changed != test.changed || reason != test.reason {
+			t.Errorf("%v: compareFiles = %q, %v, want %q, %v", test.name, reason, changed, test.reason, test.changed)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package moibit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// newSyncFixture returns a client and a local directory to sync with the remote directory /r.
+// Both sides hold an identical file, a file whose content differs and a file missing on the other side.
+// The local version of the differing file was modified at the given offset from the time of the remote one.
+func newSyncFixture(t *testing.T, offset time.Duration) (*moibit.Client, string) {
+	t.Helper()
+
+	_, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{
+		"/r/same.txt":        "same",
+		"/r/changed.txt":     "remote!",
+		"/r/remote-only.txt": "remote only",
+	})
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{
+		"same.txt":      "same",
+		"changed.txt":   "local!!",
+		"sub/local.txt": "local only",
+	})
+
+	modified := time.Now().Add(offset)
+	if err := os.Chtimes(filepath.Join(local, "changed.txt"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	return client, local
+}
+
+// planActions returns the actions of the given plan as "operation path" strings
+func planActions(plan *moibit.SyncPlan) []string {
+	actions := make([]string, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		actions = append(actions, action.Operation.String()+" "+action.Path)
+	}
+
+	return actions
+}
+
+func TestPlanSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction moibit.SyncDirection
+		offset    time.Duration
+		opts      []moibit.SyncOption
+		want      []string
+	}{
+		{"push", moibit.SyncPush, time.Hour, nil,
+			[]string{"upload changed.txt", "upload sub/local.txt"}},
+		{"push delete", moibit.SyncPush, time.Hour, []moibit.SyncOption{moibit.DeleteExtraneous()},
+			[]string{"upload changed.txt", "delete-remote remote-only.txt", "upload sub/local.txt"}},
+		{"push older", moibit.SyncPush, -time.Hour, nil,
+			[]string{"upload changed.txt", "upload sub/local.txt"}},
+		{"pull", moibit.SyncPull, time.Hour, nil,
+			[]string{"download changed.txt", "download remote-only.txt"}},
+		{"pull delete", moibit.SyncPull, time.Hour, []moibit.SyncOption{moibit.DeleteExtraneous()},
+			[]string{"download changed.txt", "download remote-only.txt", "delete-local sub/local.txt"}},
+		{"bidirectional newer local", moibit.SyncBidirectional, time.Hour, nil,
+			[]string{"upload changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+		{"bidirectional newer remote", moibit.SyncBidirectional, -time.Hour, nil,
+			[]string{"download changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+		{"bidirectional prefer local", moibit.SyncBidirectional, -time.Hour, []moibit.SyncOption{moibit.WithConflictPolicy(moibit.PreferLocal)},
+			[]string{"upload changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+		{"bidirectional prefer remote", moibit.SyncBidirectional, time.Hour, []moibit.SyncOption{moibit.WithConflictPolicy(moibit.PreferRemote)},
+			[]string{"download changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+		{"bidirectional skip conflicts", moibit.SyncBidirectional, time.Hour, []moibit.SyncOption{moibit.WithConflictPolicy(moibit.SkipConflicts)},
+			[]string{"conflict changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+		{"bidirectional never deletes", moibit.SyncBidirectional, time.Hour, []moibit.SyncOption{moibit.DeleteExtraneous()},
+			[]string{"upload changed.txt", "download remote-only.txt", "upload sub/local.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, local := newSyncFixture(t, test.offset)
+
+			plan, err := client.PlanSync(context.Background(), local, "/r", test.direction, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := planActions(plan); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("plan = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanSyncMissingDirectories(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/r/a.txt": "a"})
+
+	plan, err := client.PlanSync(context.Background(), filepath.Join(t.TempDir(), "missing"), "/r", moibit.SyncPull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := planActions(plan); !reflect.DeepEqual(got, []string{"download a.txt"}) {
+		t.Errorf("plan into a missing local directory = %v, want the download of a.txt", got)
+	}
+
+	plan, err = client.PlanSync(context.Background(), t.TempDir(), "/missing", moibit.SyncPush)
+	if err != nil || len(plan.Actions) != 0 {
+		t.Errorf("plan between missing directories = %v, %v, want no actions", plan, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	client, local := newSyncFixture(t, time.Hour)
+
+	// A dry run does not modify either side
+	plan, err := client.Sync(context.Background(), local, "/r", moibit.SyncBidirectional, moibit.DryRun())
+	if err != nil || len(plan.Actions) != 3 {
+		t.Fatalf("dry run = %v, %v, want 3 actions", plan, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(local, "remote-only.txt")); !os.IsNotExist(err) {
+		t.Errorf("dry run downloaded remote-only.txt: %v", err)
+	}
+
+	if _, err := client.Sync(context.Background(), local, "/r", moibit.SyncBidirectional); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"same.txt": "same", "changed.txt": "local!!", "remote-only.txt": "remote only", "sub/local.txt": "local only"}
+	if got := localFiles(t, local); !reflect.DeepEqual(got, want) {
+		t.Errorf("local files = %v, want %v", got, want)
+	}
+
+	for name, text := range want {
+		data, err := client.ReadFile("/r/"+name, 0)
+		if err != nil || string(data) != text {
+			t.Errorf("remote %v = %q, %v, want %q", name, data, err, text)
+		}
+	}
+
+	// Both sides are in sync
+	plan, err = client.PlanSync(context.Background(), local, "/r", moibit.SyncBidirectional)
+	if err != nil || len(plan.Actions) != 0 {
+		t.Errorf("plan after a sync = %v, %v, want no actions", plan, err)
+	}
+}
+
+func TestSyncDeleteExtraneous(t *testing.T) {
+	client, local := newSyncFixture(t, time.Hour)
+
+	if _, err := client.Sync(context.Background(), local, "/r", moibit.SyncPull, moibit.DeleteExtraneous()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"same.txt": "same", "changed.txt": "remote!", "remote-only.txt": "remote only"}
+	if got := localFiles(t, local); !reflect.DeepEqual(got, want) {
+		t.Errorf("local files after a pull = %v, want %v", got, want)
+	}
+
+	writeLocalFiles(t, local, map[string]string{"new.txt": "new"})
+	if err := os.Remove(filepath.Join(local, "same.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Sync(context.Background(), local, "/r", moibit.SyncPush, moibit.DeleteExtraneous()); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := client.FileStatus("/r/same.txt"); status.Exists() {
+		t.Error("push did not delete the extraneous remote file")
+	}
+
+	if data, err := client.ReadFile("/r/new.txt", 0); err != nil || string(data) != "new" {
+		t.Errorf("push did not upload new.txt: %q, %v", data, err)
+	}
+}
+
+func TestSyncSettles(t *testing.T) {
+	// Encrypted files can only be compared by their modification time
+	_, client := newTestClient(t, moibit.ClientEncryption(staticKey(t, "sync", 1)))
+	writeFiles(t, client, map[string]string{"/r/remote-only.txt": "remote only"})
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+
+	// The local files were modified long before they are uploaded
+	modified := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := os.Chtimes(filepath.Join(local, filepath.FromSlash(name)), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := client.Sync(context.Background(), local, "/r", moibit.SyncBidirectional, moibit.SettleModTimes())
+	if err != nil || len(plan.Actions) != 3 {
+		t.Fatalf("first sync = %v, %v, want 3 actions", plan, err)
+	}
+
+	// The uploads are not mistaken for remote modifications by the next sync
+	plan, err = client.Sync(context.Background(), local, "/r", moibit.SyncBidirectional)
+	if err != nil || len(plan.Actions) != 0 {
+		t.Errorf("second sync = %v, %v, want no actions", plan, err)
+	}
+}
+
+func TestSyncKeepsModTimes(t *testing.T) {
+	_, client := newTestClient(t, moibit.ClientEncryption(staticKey(t, "sync", 1)))
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "a"})
+
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(local, "a.txt"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Sync(context.Background(), local, "/r", moibit.SyncPush); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without SettleModTimes, the local files are not modified by the sync
+	info, err := os.Stat(filepath.Join(local, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.ModTime().Equal(modified) {
+		t.Errorf("modification time of a.txt after a push = %v, want %v", info.ModTime(), modified)
+	}
+
+	// The upload is newer than the local file
+	plan, err := client.PlanSync(context.Background(), local, "/r", moibit.SyncPull)
+	if err != nil || len(plan.Actions) != 1 || plan.Actions[0].Operation != moibit.SyncDownload {
+		t.Errorf("pull plan after a push = %v, %v, want a.txt downloaded", plan, err)
+	}
+}
+
+func TestSyncDecompressed(t *testing.T) {
+	_, client := newTestClient(t)
+
+	data := logData(10000)
+	if _, err := client.WriteFile(data, "/r/app.json", moibit.Compress(moibit.GzipCompression)); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, client, map[string]string{"/r/plain.txt": "plain"})
+
+	local := t.TempDir()
+	if _, err := client.Sync(context.Background(), local, "/r", moibit.SyncPull); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := localFiles(t, local)["app.json"]; got != string(data) {
+		t.Errorf("pulled app.json of %v bytes, want the decompressed %v bytes", len(got), len(data))
+	}
+
+	// The size and hash of the compressed file describe the compressed data, so it is compared by its modification time
+	for _, direction := range []moibit.SyncDirection{moibit.SyncPull, moibit.SyncBidirectional} {
+		plan, err := client.PlanSync(context.Background(), local, "/r", direction)
+		if err != nil || len(plan.Actions) != 0 {
+			t.Errorf("%v plan after a pull = %v, %v, want no actions", direction, plan, err)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransferOption is an option for the UploadDir and DownloadDir methods of Client.
+type TransferOption func(*transferConfig) error
+
+// transferConfig is the configuration of a directory transfer
+type transferConfig struct {
+	concurrency int
+	writeOpts   []WriteOption
+	readOpts    []ReadOption
+
+	version int
+	include []string
+	exclude []string
+
+	skipUnchanged bool
+}
+
+// defaultTransferConfig returns the default configuration of a directory transfer
+func defaultTransferConfig() *transferConfig {
+	return &transferConfig{concurrency: 4}
+}
+
+// TransferConcurrency returns a TransferOption that can be used to set the
+// number of files that are transferred concurrently. The default is 4.
+func TransferConcurrency(n int) TransferOption {
+	return func(config *transferConfig) error {
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency: %v", n)
+		}
+
+		config.concurrency = n
+		return nil
+	}
+}
+
+// WithWriteOptions returns a TransferOption that can be used to set the WriteOption
+// applied to every file uploaded, such as encryption, replication or provenance.
+func WithWriteOptions(opts ...WriteOption) TransferOption {
+	return func(config *transferConfig) error {
+		config.writeOpts = append(config.writeOpts, opts...)
+		return nil
+	}
+}
+
+// WithReadOptions returns a TransferOption that can be used to set the ReadOption
+// applied to every file downloaded, such as Verify to check the integrity of the files.
+func WithReadOptions(opts ...ReadOption) TransferOption {
+	return func(config *transferConfig) error {
+		config.readOpts = append(config.readOpts, opts...)
+		return nil
+	}
+}
+
+// AtVersion returns a TransferOption that can be used to download the given version of every file.
+// The latest version of every file is downloaded by default.
+func AtVersion(version int) TransferOption {
+	return func(config *transferConfig) error {
+		config.version = version
+		return nil
+	}
+}
+
+// Include returns a TransferOption that can be used to only download the files whose path relative
+// to the remote directory matches any of the given patterns. The patterns follow the syntax of Glob.
+func Include(patterns ...string) TransferOption {
+	return func(config *transferConfig) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		config.include = append(config.include, patterns...)
+		return nil
+	}
+}
+
+// Exclude returns a TransferOption that can be used to skip the files and directories whose path relative
+// to the remote directory matches any of the given patterns. The patterns follow the syntax of Glob.
+func Exclude(patterns ...string) TransferOption {
+	return func(config *transferConfig) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		config.exclude = append(config.exclude, patterns...)
+		return nil
+	}
+}
+
+// SkipUnchanged returns a TransferOption that can be used to skip the files whose content is already
+// identical at the destination. The content is compared by computing the hash of the local file with
+// HashFile and comparing it with the hash of the file on MOIBit, so no file data is transferred.
+// For UploadDir, this costs a FileStatus request for every file.
+// Files encrypted or compressed on the client are never skipped, nor are local files that would be
+// decompressed when they are read (because they start with the compression header) when they are downloaded.
+func SkipUnchanged() TransferOption {
+	return func(config *transferConfig) error {
+		config.skipUnchanged = true
+		return nil
+	}
+}
+
+// compressed returns whether the WriteOption of the transfer compress the files on the client
+func (config *transferConfig) compressed() bool {
+	request := defaultWriteFileRequest("")
+	for _, opt := range config.writeOpts {
+		_ = opt(request)
+	}
+
+	return request.compression != NoCompression
+}
+
+// validatePatterns returns path.ErrBadPattern if any element of the given patterns is malformed
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		for _, element := range splitPath(pattern) {
+			if _, err := path.Match(element, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%v': %w", pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchAny returns whether the given relative path matches any of the given patterns
+func matchAny(patterns []string, relative string) bool {
+	for _, pattern := range patterns {
+		if matchElements(splitPath(pattern), splitPath(relative)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransferResult is the result of transferring a single file between the local file system and MOIBit
+type TransferResult struct {
+	// Path of the file on the local file system
+	LocalPath string
+	// Path of the file on MOIBit
+	RemotePath string
+	// Number of bytes of file data transferred
+	Size int64
+	// Status of the file on MOIBit after the transfer
+	File FileDescriptor
+	// Error that caused the transfer of the file to fail, if any
+	Err error
+	// Whether the transfer was skipped because the file was unchanged,
+	// or because the local file is not a regular file (see UploadDir)
+	Skipped bool
+}
+
+// UploadDir uploads the tree of files rooted at the given local directory into the given directory on MOIBit.
+// Every local directory is created on MOIBit with MakeDirectory, and every regular file is streamed with
+// WriteFrom by a bounded pool of workers. Symbolic links and other files that are not regular (devices, pipes
+// and sockets) are neither followed nor uploaded, they are reported as skipped results. It accepts a variadic
+// number of TransferOption to set the concurrency, the WriteOption for each file and to skip unchanged files.
+// Returns a TransferResult for every file, in the order of the local walk, and an error joining the errors of
+// all files that failed to upload.
+func (client *Client) UploadDir(ctx context.Context, localDir, remoteDir string, opts ...TransferOption) ([]TransferResult, error) {
+	config := defaultTransferConfig()
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, fmt.Errorf("transfer option could not be applied: %w", err)
+		}
+	}
+
+	remoteDir = path.Join("/", remoteDir)
+
+	// Collect the directories and files of the local tree
+	directories := make([]string, 0)
+	results := make([]TransferResult, 0)
+
+	err := filepath.WalkDir(localDir, func(localpath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(localDir, localpath)
+		if err != nil {
+			return err
+		}
+
+		remotepath := path.Join(remoteDir, filepath.ToSlash(relative))
+
+		switch {
+		case entry.IsDir():
+			directories = append(directories, remotepath)
+		case entry.Type().IsRegular():
+			results = append(results, TransferResult{LocalPath: localpath, RemotePath: remotepath})
+		default:
+			results = append(results, TransferResult{LocalPath: localpath, RemotePath: remotepath, Skipped: true})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("local walk failed: %w", err)
+	}
+
+	// Create the remote directories, parents are always visited before their children.
+	// Retries are safe because directories that already exist are accepted.
+	for _, dirpath := range directories {
+		if dirpath == "/" {
+			continue
+		}
+
+		if err := client.MakeDirectoryContext(ctx, dirpath, RetryMakeDir()); err != nil && !errors.Is(err, ErrAlreadyExists) {
+			return nil, fmt.Errorf("directory '%v' could not be created: %w", dirpath, err)
+		}
+	}
+
+	// Upload the files with a pool of workers
+	parallel(config.concurrency, len(results), func(i int) {
+		result := &results[i]
+		if result.Skipped {
+			return
+		}
+
+		if result.Err = ctx.Err(); result.Err != nil {
+			return
+		}
+
+		if config.skipUnchanged && !config.compressed() {
+			if file, err := client.FileStatusContext(ctx, result.RemotePath); err == nil && client.unchanged(result.LocalPath, file) {
+				result.File, result.Skipped = file, true
+				return
+			}
+		}
+
+		result.File, result.Size, result.Err = client.uploadLocalFile(ctx, result.LocalPath, result.RemotePath, config.writeOpts)
+	})
+
+	return results, transferErrors(results)
+}
+
+// DownloadDir downloads the tree of files rooted at the given directory on MOIBit into the given local directory.
+// The remote tree is walked with Walk and its layout is recreated in the local directory, every file is streamed
+// into a temporary file which is renamed into place once complete, so partially downloaded files are never visible.
+// Local directories are only created for the files that are downloaded, directories without any are not recreated.
+// The modification time of every local file is set to the time the file was last updated on MOIBit.
+// It accepts a variadic number of TransferOption to set the concurrency, the version and ReadOption of the files,
+// the Include and Exclude patterns and to skip unchanged files. Returns a TransferResult for every file, in the order of the remote walk,
+// and an error joining the errors of all files that failed to download.
+func (client *Client) DownloadDir(ctx context.Context, remoteDir, localDir string, opts ...TransferOption) ([]TransferResult, error) {
+	config := defaultTransferConfig()
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, fmt.Errorf("transfer option could not be applied: %w", err)
+		}
+	}
+
+	remoteDir = path.Join("/", remoteDir)
+
+	// Collect the files of the remote tree
+	results := make([]TransferResult, 0)
+	err := client.Walk(ctx, remoteDir, func(remotepath string, file FileDescriptor, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Guard against paths that escape the remote directory
+		relative, ok := relativePath(remoteDir, remotepath)
+		if !ok {
+			return fmt.Errorf("remote path '%v' escapes the remote directory", remotepath)
+		}
+
+		localpath := filepath.Join(localDir, filepath.FromSlash(relative))
+
+		if relative != "" && matchAny(config.exclude, relative) {
+			if file.IsDirectory {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		// Local directories are only created for the files that are downloaded into them
+		if file.IsDirectory {
+			return nil
+		}
+
+		if len(config.include) == 0 || matchAny(config.include, relative) {
+			results = append(results, TransferResult{LocalPath: localpath, RemotePath: remotepath, File: file})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("remote walk failed: %w", err)
+	}
+
+	// Download the files with a pool of workers
+	parallel(config.concurrency, len(results), func(i int) {
+		result := &results[i]
+		if result.Err = ctx.Err(); result.Err != nil {
+			return
+		}
+
+		// Only the latest version of a file is described by the walk
+		// Files compressed on the client are decompressed when they are downloaded, so a local file can only be
+		// identical to the remote data it is downloaded from if the remote file is not compressed
+		if config.skipUnchanged && config.version == 0 && client.unchanged(result.LocalPath, result.File) &&
+			!(decompresses(config.readOpts) && isCompressedLocalFile(result.LocalPath)) {
+			result.Skipped = true
+			return
+		}
+
+		result.Size, result.Err = client.downloadRemoteFile(ctx, result.RemotePath, result.LocalPath, config.version, parseTimestamp(result.File.LastUpdated), config.readOpts)
+	})
+
+	return results, transferErrors(results)
+}
+
+// unchanged returns whether the local file at the given path has the same content as the given file on MOIBit.
+// Returns false if the local file does not exist, the hash of the remote file is not a CID or
+// the files are encrypted on the client, since the hash of the remote file describes the ciphertext.
+func (client *Client) unchanged(localpath string, remote FileDescriptor) bool {
+	version, ok := CIDVersionOf(remote.Hash)
+	if !ok || !remote.Exists() || remote.IsDirectory || client.keys != nil {
+		return false
+	}
+
+	info, err := os.Stat(localpath)
+	if err != nil || info.Size() != int64(remote.FileSize) {
+		return false
+	}
+
+	hash, err := HashFile(localpath, version)
+	return err == nil && hash == remote.Hash
+}
+
+// relativePath returns the given path relative to the given directory.
+// Returns false if the path is not the directory or inside it.
+func relativePath(dir, p string) (string, bool) {
+	switch {
+	case p == dir:
+		return "", true
+	case dir == "/":
+		return strings.TrimPrefix(p, "/"), strings.HasPrefix(p, "/")
+	case strings.HasPrefix(p, dir+"/"):
+		return strings.TrimPrefix(p, dir+"/"), true
+	default:
+		return "", false
+	}
+}
+
+// downloadRemoteFile streams the given version of the file at the given path on MOIBit into the local file
+// at the given path. The data is written into a temporary file in the same directory which is renamed once complete.
+// The modification time of the local file is set to the given time, unless it is zero. The local file keeps
+// the permissions of the file it replaces, and is created with the permissions 0644 otherwise.
+// The file is not renamed into place if the read fails, including a failed verification.
+func (client *Client) downloadRemoteFile(ctx context.Context, remotepath, localpath string, version int, modified time.Time, opts []ReadOption) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(localpath), 0755); err != nil {
+		return 0, err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(localpath), "."+filepath.Base(localpath)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+
+	// Remove the temporary file unless it is renamed
+	defer os.Remove(temp.Name())
+
+	// Temporary files are only accessible to the owner, which is not wanted for the downloaded file
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(localpath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		return 0, err
+	}
+
+	written, err := client.ReadFileToContext(ctx, temp, remotepath, version, opts...)
+	if err != nil {
+		temp.Close()
+		return written, err
+	}
+
+	if err := temp.Close(); err != nil {
+		return written, err
+	}
+
+	if !modified.IsZero() {
+		if err := os.Chtimes(temp.Name(), modified, modified); err != nil {
+			return written, err
+		}
+	}
+
+	if err := os.Rename(temp.Name(), localpath); err != nil {
+		return written, err
+	}
+
+	return written, nil
+}
+
+// uploadLocalFile streams the local file at the given path to the given path on MOIBit
+func (client *Client) uploadLocalFile(ctx context.Context, localpath, remotepath string, opts []WriteOption) (FileDescriptor, int64, error) {
+	file, err := os.Open(localpath)
+	if err != nil {
+		return FileDescriptor{}, 0, err
+	}
+
+	defer file.Close()
+
+	// Count the bytes sent with a progress reader
+	counter := &progressReader{r: file, progress: func(int64) {}}
+	descriptor, err := client.WriteFrom(ctx, counter, remotepath, opts...)
+	return descriptor, counter.sent, err
+}
+
+// parallel calls the given function for every index in [0, count) with a pool of the given number of workers.
+// Returns once all the calls are complete.
+func parallel(workers, count int, fn func(i int)) {
+	jobs := make(chan int)
+	group := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		group.Add(1)
+
+		go func() {
+			defer group.Done()
+
+			for job := range jobs {
+				fn(job)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+
+	close(jobs)
+	group.Wait()
+}
+
+// transferErrors joins the errors of all failed results, annotated with their paths.
+// Returns nil if all results succeeded.
+func transferErrors(results []TransferResult) error {
+	errs := make([]error, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", result.RemotePath, result.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,452 @@
+package moibit_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// writeLocalFiles writes the given files (slash separated path to text) into the given local directory
+func writeLocalFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, text := range files {
+		localpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(localpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(localpath, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// resultPaths returns the remote paths of the given results, marking the skipped results with a trailing "!"
+func resultPaths(results []moibit.TransferResult) []string {
+	paths := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Skipped {
+			paths = append(paths, result.RemotePath+"!")
+		} else {
+			paths = append(paths, result.RemotePath)
+		}
+	}
+
+	return paths
+}
+
+func TestUploadDir(t *testing.T) {
+	_, client := newTestClient(t)
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{
+		"a.txt":       "alpha",
+		"dir/b.txt":   "bravo",
+		"dir/c/d.txt": "delta",
+	})
+
+	if err := os.MkdirAll(filepath.Join(local, "vacant"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.UploadDir(context.Background(), local, "/backup", moibit.TransferConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/backup/a.txt", "/backup/dir/b.txt", "/backup/dir/c/d.txt"}
+	if got := resultPaths(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("UploadDir results = %v, want %v", got, want)
+	}
+
+	for _, result := range results {
+		data, err := client.ReadFile(result.RemotePath, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if local, _ := os.ReadFile(result.LocalPath); string(data) != string(local) || result.Size != int64(len(local)) || !result.File.Exists() {
+			t.Errorf("%v uploaded as %q (%v bytes), want %q", result.RemotePath, data, result.Size, local)
+		}
+	}
+
+	// Empty local directories are created on MOIBit
+	if status, err := client.FileStatus("/backup/vacant"); err != nil || !status.IsDirectory {
+		t.Errorf("FileStatus of an empty directory = %+v, %v, want a directory", status, err)
+	}
+}
+
+func TestUploadDirSkipsIrregularFiles(t *testing.T) {
+	_, client := newTestClient(t)
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "alpha", "dir/b.txt": "bravo"})
+
+	if err := os.Symlink(filepath.Join(local, "a.txt"), filepath.Join(local, "link.txt")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+
+	if err := os.Symlink(filepath.Join(local, "dir"), filepath.Join(local, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.UploadDir(context.Background(), local, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/a.txt", "/dir/b.txt", "/link.txt!", "/linkdir!"}
+	if got := resultPaths(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("UploadDir results = %v, want %v", got, want)
+	}
+
+	for _, remotepath := range []string{"/link.txt", "/linkdir"} {
+		if status, _ := client.FileStatus(remotepath); status.Exists() {
+			t.Errorf("symbolic link %v was uploaded", remotepath)
+		}
+	}
+}
+
+func TestUploadDirSkipUnchanged(t *testing.T) {
+	server, client := newTestClient(t)
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+
+	if _, err := client.UploadDir(context.Background(), local, "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeLocalFiles(t, local, map[string]string{"b.txt": "bravo, changed", "c.txt": "charlie"})
+	before := server.Calls("/writefile")
+
+	results, err := client.UploadDir(context.Background(), local, "/", moibit.SkipUnchanged())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/a.txt!", "/b.txt", "/c.txt"}
+	if got := resultPaths(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("UploadDir results = %v, want %v", got, want)
+	}
+
+	if calls := server.Calls("/writefile") - before; calls != 2 {
+		t.Errorf("%v files uploaded, want the 2 changed files", calls)
+	}
+
+	if !results[0].File.Exists() || results[0].Size != 0 {
+		t.Errorf("skipped result = %+v, want the remote status without any data transferred", results[0])
+	}
+}
+
+func TestUploadDirErrors(t *testing.T) {
+	_, client := newTestClient(t)
+
+	if _, err := client.UploadDir(context.Background(), filepath.Join(t.TempDir(), "missing"), "/"); err == nil {
+		t.Error("UploadDir of a missing local directory succeeded")
+	}
+
+	if _, err := client.UploadDir(context.Background(), t.TempDir(), "/", moibit.TransferConcurrency(0)); err == nil {
+		t.Error("UploadDir with concurrency 0 succeeded")
+	}
+
+	// A remote file in place of a directory fails the upload
+	writeFiles(t, client, map[string]string{"/dir": "file"})
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"dir/a.txt": "alpha"})
+
+	if _, err := client.UploadDir(context.Background(), local, "/"); err == nil {
+		t.Error("UploadDir over a remote file succeeded")
+	}
+}
+
+// localFiles returns the files (slash separated path to text) in the given local directory
+func localFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(localpath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, err := os.ReadFile(localpath)
+		if err != nil {
+			return err
+		}
+
+		relative, _ := filepath.Rel(dir, localpath)
+		files[filepath.ToSlash(relative)] = string(data)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// localDirectories returns the sorted slash separated paths of the directories inside the given local directory
+func localDirectories(t *testing.T, dir string) []string {
+	t.Helper()
+
+	dirs := make([]string, 0)
+	err := filepath.WalkDir(dir, func(localpath string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || localpath == dir {
+			return err
+		}
+
+		relative, _ := filepath.Rel(dir, localpath)
+		dirs = append(dirs, filepath.ToSlash(relative))
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(dirs)
+	return dirs
+}
+
+// directoriesOf returns the sorted set of the parent directories of the given slash separated file paths
+func directoriesOf(files []string) []string {
+	set := make(map[string]bool)
+	for _, file := range files {
+		for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+			set[dir] = true
+		}
+	}
+
+	dirs := make([]string, 0, len(set))
+	for dir := range set {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+	return dirs
+}
+
+// downloadTree is the tree of files downloaded by the tests
+var downloadTree = map[string]string{
+	"/site/index.html":        "<html>",
+	"/site/css/main.css":      "body {}",
+	"/site/js/app.js":         "app()",
+	"/site/js/vendor/lib.js":  "lib()",
+	"/site/drafts/post.html":  "<draft>",
+	"/site/drafts/notes.txt":  "notes",
+	"/elsewhere/ignored.html": "<ignored>",
+}
+
+func TestDownloadDir(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, downloadTree)
+
+	if err := client.MakeDirectory("/site/vacant"); err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	results, err := client.DownloadDir(context.Background(), "/site", local, moibit.TransferConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/site/css/main.css", "/site/drafts/notes.txt", "/site/drafts/post.html", "/site/index.html", "/site/js/app.js", "/site/js/vendor/lib.js"}
+	if got := resultPaths(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("DownloadDir results = %v, want %v", got, want)
+	}
+
+	files := map[string]string{
+		"index.html": "<html>", "css/main.css": "body {}", "js/app.js": "app()",
+		"js/vendor/lib.js": "lib()", "drafts/post.html": "<draft>", "drafts/notes.txt": "notes",
+	}
+
+	if got := localFiles(t, local); !reflect.DeepEqual(got, files) {
+		t.Errorf("downloaded files = %v, want %v", got, files)
+	}
+
+	// Empty remote directories are not created locally
+	if _, err := os.Stat(filepath.Join(local, "vacant")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("empty directory was created: %v", err)
+	}
+
+	// The modification time is the time the file was last updated
+	info, err := os.Stat(filepath.Join(local, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := time.Parse(time.RFC3339, results[3].File.LastUpdated)
+	if !info.ModTime().Equal(updated) {
+		t.Errorf("modification time = %v, want %v", info.ModTime(), updated)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// Downloaded files are not only accessible to the owner, and replaced files keep their permissions
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0644))
+	}
+
+	if err := os.Chmod(filepath.Join(local, "index.html"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.DownloadDir(context.Background(), "/site", local); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err = os.Stat(filepath.Join(local, "index.html")); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of the replaced file = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+}
+
+func TestDownloadDirPatterns(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, downloadTree)
+
+	tests := []struct {
+		name string
+		opts []moibit.TransferOption
+		want []string
+	}{
+		{"include", []moibit.TransferOption{moibit.Include("*.html")}, []string{"index.html"}},
+		{"include recursive", []moibit.TransferOption{moibit.Include("**/*.html")}, []string{"drafts/post.html", "index.html"}},
+		{"include several", []moibit.TransferOption{moibit.Include("**/*.js", "css/*")}, []string{"css/main.css", "js/app.js", "js/vendor/lib.js"}},
+		{"exclude directory", []moibit.TransferOption{moibit.Exclude("drafts", "js/vendor")}, []string{"css/main.css", "index.html", "js/app.js"}},
+		{"exclude files", []moibit.TransferOption{moibit.Exclude("**/*.js")}, []string{"css/main.css", "drafts/notes.txt", "drafts/post.html", "index.html"}},
+		{"include and exclude", []moibit.TransferOption{moibit.Include("**/*.html"), moibit.Exclude("drafts")}, []string{"index.html"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			local := t.TempDir()
+			if _, err := client.DownloadDir(context.Background(), "/site", local, test.opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0)
+			for name := range localFiles(t, local) {
+				got = append(got, name)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("downloaded files = %v, want %v", got, test.want)
+			}
+
+			// Only the directories of the downloaded files are created
+			if dirs := localDirectories(t, local); !reflect.DeepEqual(dirs, directoriesOf(test.want)) {
+				t.Errorf("local directories = %v, want %v", dirs, directoriesOf(test.want))
+			}
+		})
+	}
+
+	if _, err := client.DownloadDir(context.Background(), "/site", t.TempDir(), moibit.Include("[")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("DownloadDir with an invalid pattern = %v, want %v", err, path.ErrBadPattern)
+	}
+}
+
+func TestDownloadDirVersion(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/docs/a.txt": "first"})
+
+	if _, err := client.WriteFile([]byte("second"), "/docs/a.txt", moibit.KeepPrevious()); err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	if _, err := client.DownloadDir(context.Background(), "/docs", local, moibit.AtVersion(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := localFiles(t, local); got["a.txt"] != "first" {
+		t.Errorf("downloaded version 1 = %q, want %q", got["a.txt"], "first")
+	}
+}
+
+func TestDownloadDirSkipUnchanged(t *testing.T) {
+	server, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/docs/a.txt": "alpha", "/docs/b.txt": "bravo", "/docs/c.txt": "charlie"})
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "alpha", "b.txt": "bravo, changed"})
+	before := server.Calls("/readfile")
+
+	results, err := client.DownloadDir(context.Background(), "/docs", local, moibit.SkipUnchanged())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/docs/a.txt!", "/docs/b.txt", "/docs/c.txt"}
+	if got := resultPaths(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("DownloadDir results = %v, want %v", got, want)
+	}
+
+	if calls := server.Calls("/readfile") - before; calls != 2 {
+		t.Errorf("%v files downloaded, want the 2 changed files", calls)
+	}
+
+	files := map[string]string{"a.txt": "alpha", "b.txt": "bravo", "c.txt": "charlie"}
+	if got := localFiles(t, local); !reflect.DeepEqual(got, files) {
+		t.Errorf("local files = %v, want %v", got, files)
+	}
+}
+
+func TestDownloadDirAtomic(t *testing.T) {
+	server, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/docs/a.txt": "the new contents of the file"})
+
+	local := t.TempDir()
+	writeLocalFiles(t, local, map[string]string{"a.txt": "old contents"})
+
+	// A verified read of a truncated response fails and leaves the local file untouched
+	server.Inject(moibittest.Fault{Endpoint: "/readfile", Truncate: 7})
+
+	results, err := client.DownloadDir(context.Background(), "/docs", local, moibit.WithReadOptions(moibit.Verify()))
+	if !errors.Is(err, moibit.ErrIntegrity) || len(results) != 1 || !errors.Is(results[0].Err, moibit.ErrIntegrity) {
+		t.Fatalf("DownloadDir of a truncated file = %v, want %v", err, moibit.ErrIntegrity)
+	}
+
+	if got := localFiles(t, local); !reflect.DeepEqual(got, map[string]string{"a.txt": "old contents"}) {
+		t.Errorf("local files after a failed download = %v, want the untouched file without temporary files", got)
+	}
+
+	// The download succeeds once the response is complete
+	if _, err := client.DownloadDir(context.Background(), "/docs", local, moibit.WithReadOptions(moibit.Verify())); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := localFiles(t, local); !reflect.DeepEqual(got, map[string]string{"a.txt": "the new contents of the file"}) {
+		t.Errorf("local files after the download = %v, want the new file", got)
+	}
+}
+
+func TestDownloadDirErrors(t *testing.T) {
+	_, client := newTestClient(t)
+
+	if _, err := client.DownloadDir(context.Background(), "/missing", t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DownloadDir of a missing directory = %v, want %v", err, fs.ErrNotExist)
+	}
+}
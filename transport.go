@@ -0,0 +1,65 @@
+package moibit
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Middleware wraps an http.RoundTripper with additional behaviour such as logging, header injection or tracing.
+// The returned RoundTripper must call the wrapped RoundTripper to perform the request.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as an http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements the http.RoundTripper interface for RoundTripperFunc
+func (fn RoundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return fn(request)
+}
+
+// HTTPClient returns a ClientOption that can be used to set the http.Client used by a Client.
+// It can be used to configure proxies, TLS, timeouts and connection pooling for the requests to MOIBit.
+// The given http.Client is not modified, even if middleware is applied with the WrapTransport option.
+func HTTPClient(c *http.Client) ClientOption {
+	return func(client *Client) error {
+		if c == nil {
+			return fmt.Errorf("nil http client")
+		}
+
+		client.c = c
+		return nil
+	}
+}
+
+// WrapTransport returns a ClientOption that can be used to wrap the transport of a Client with a chain of Middleware.
+// The middleware are applied in the order they are given, the first middleware being the outermost one.
+// Multiple WrapTransport options append to the chain, which is applied after all other options.
+func WrapTransport(middleware ...Middleware) ClientOption {
+	return func(client *Client) error {
+		client.middleware = append(client.middleware, middleware...)
+		return nil
+	}
+}
+
+// applyMiddleware wraps the transport of the http.Client of the client with its middleware chain.
+// The http.Client is copied before its transport is replaced, so that the original is not modified.
+func (client *Client) applyMiddleware() {
+	if len(client.middleware) == 0 {
+		return
+	}
+
+	// Start from the transport of the http.Client, or the default one
+	transport := client.c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// Wrap the transport from the innermost middleware to the outermost
+	for i := len(client.middleware) - 1; i >= 0; i-- {
+		transport = client.middleware[i](transport)
+	}
+
+	c := *client.c
+	c.Transport = transport
+	client.c = &c
+}
@@ -0,0 +1,96 @@
+package moibit_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// recorder is a Middleware factory that records the order in which requests pass through the middleware
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// middleware returns a Middleware that records the given name before and after the wrapped transport
+func (rec *recorder) middleware(name string) moibit.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return moibit.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			rec.record(name + ">")
+			response, err := next.RoundTrip(request)
+			rec.record("<" + name)
+			return response, err
+		})
+	}
+}
+
+// record appends an event to the recorder
+func (rec *recorder) record(event string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.events = append(rec.events, event)
+}
+
+// reset clears the events of the recorder
+func (rec *recorder) reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.events = nil
+}
+
+func TestWrapTransportOrder(t *testing.T) {
+	rec := new(recorder)
+	_, client := newTestClient(t,
+		moibit.WrapTransport(rec.middleware("a"), rec.middleware("b")),
+		moibit.WrapTransport(rec.middleware("c")),
+	)
+
+	rec.reset()
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a>", "b>", "c>", "<c", "<b", "<a"}
+	if len(rec.events) != len(want) {
+		t.Fatalf("events = %v, want %v", rec.events, want)
+	}
+
+	for i := range want {
+		if rec.events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", rec.events, want)
+		}
+	}
+}
+
+func TestWrapTransportAuthentication(t *testing.T) {
+	rec := new(recorder)
+	newTestClient(t, moibit.WrapTransport(rec.middleware("auth")))
+
+	if len(rec.events) != 2 {
+		t.Errorf("events = %v, want the authentication request to pass through the middleware", rec.events)
+	}
+}
+
+func TestHTTPClientNotModified(t *testing.T) {
+	c := &http.Client{}
+	rec := new(recorder)
+
+	_, client := newTestClient(t, moibit.HTTPClient(c), moibit.WrapTransport(rec.middleware("a")))
+	if c.Transport != nil {
+		t.Error("the transport of the given http.Client was modified")
+	}
+
+	if _, err := client.ListFiles("/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPClientNil(t *testing.T) {
+	if _, err := moibit.NewClient("signature", "nonce", moibit.HTTPClient(nil)); err == nil {
+		t.Error("nil http client was accepted")
+	}
+}
@@ -0,0 +1,176 @@
+package moibit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// uploadFile writes the given file data to MOIBit as binary data in a multipart form.
+// The form is generated in memory so that the request can be retried if allowed.
+func (client *Client) uploadFile(ctx context.Context, data []byte, request *requestWriteFile) (FileDescriptor, error) {
+	// Generate Request Data
+	requestData := new(bytes.Buffer)
+	writer := multipart.NewWriter(requestData)
+	if err := request.writeMultipart(writer, bytes.NewReader(data)); err != nil {
+		return FileDescriptor{}, fmt.Errorf("request serialization failed: %w", err)
+	}
+
+	// Generate Request Object
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/writefile"), bytes.NewReader(requestData.Bytes()))
+	if err != nil {
+		return FileDescriptor{}, fmt.Errorf("request generation failed: %w", err)
+	}
+
+	// Set authentication and content headers
+	client.setHeaders(requestHTTP)
+	requestHTTP.Header.Set("Content-Type", writer.FormDataContentType())
+
+	// Perform the HTTP Request
+	responseHTTP, err := client.do(requestHTTP, request.retry)
+	if err != nil {
+		return FileDescriptor{}, fmt.Errorf("request failed: %w", err)
+	}
+
+	defer responseHTTP.Body.Close()
+
+	return decodeWriteFile("/writefile", responseHTTP)
+}
+
+// WriteFrom writes a file to MOIBit with the data streamed from the given reader and the given file name.
+// It accepts the same WriteOption as WriteFile. The file is always uploaded as binary data in a multipart form
+// which is streamed to MOIBit as it is generated, so the data is never held in memory in its entirety.
+// Progress of the upload can be reported with the Progress option. The request is never retried, since
+// the data from the reader cannot be replayed.
+// Returns a FileDescriptor (and error) containing the status of the file after successful write.
+func (client *Client) WriteFrom(ctx context.Context, r io.Reader, name string, opts ...WriteOption) (FileDescriptor, error) {
+	// Generate Request Data
+	request := defaultWriteFileRequest(name)
+	for _, opt := range opts {
+		if err := opt(request); err != nil {
+			return FileDescriptor{}, fmt.Errorf("request creation failed while applying options: %w", err)
+		}
+	}
+
+	// Report the progress of the file data if required
+	if request.progress != nil {
+		r = &progressReader{r: r, progress: request.progress}
+	}
+
+	// Compress and encrypt the file data on the client as it is streamed if required
+	if !request.raw {
+		r = compressStream(r, request)
+	}
+
+	if client.keys != nil && !request.raw {
+		encrypted, err := client.encrypt(ctx, r)
+		if err != nil {
+			return FileDescriptor{}, fmt.Errorf("file encryption failed: %w", err)
+		}
+
+		r = encrypted
+	}
+
+	// Stream the multipart form through a pipe into the request body.
+	// Closing the reader end aborts the generation of the form.
+	reader, writer := io.Pipe()
+	defer reader.Close()
+
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(request.writeMultipart(form, r))
+	}()
+
+	// Generate Request Object
+	requestHTTP, err := http.NewRequestWithContext(ctx, "POST", client.serviceURL("/writefile"), reader)
+	if err != nil {
+		return FileDescriptor{}, fmt.Errorf("request generation failed: %w", err)
+	}
+
+	// Set authentication and content headers
+	client.setHeaders(requestHTTP)
+	requestHTTP.Header.Set("Content-Type", form.FormDataContentType())
+
+	// Perform the HTTP Request
+	responseHTTP, err := client.do(requestHTTP, false)
+	if err != nil {
+		return FileDescriptor{}, fmt.Errorf("request failed: %w", err)
+	}
+
+	defer responseHTTP.Body.Close()
+
+	return decodeWriteFile("/writefile", responseHTTP)
+}
+
+// Progress returns a WriteOption that can be used to report the progress of an upload with WriteFrom.
+// The given function is called with the total number of bytes of file data sent so far, every time
+// more data is read from the reader. It is called from the goroutine that generates the request body.
+func Progress(fn func(sent int64)) WriteOption {
+	return func(request *requestWriteFile) error {
+		request.progress = fn
+		return nil
+	}
+}
+
+// progressReader is an io.Reader that reports the number of bytes read from the wrapped reader
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	progress func(sent int64)
+}
+
+// Read implements the io.Reader interface for progressReader
+func (reader *progressReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+	if n > 0 {
+		reader.sent += int64(n)
+		reader.progress(reader.sent)
+	}
+
+	return n, err
+}
+
+// writeMultipart writes the write request as a multipart form into the given multipart.Writer.
+// The fields of the request are written as form fields followed by the file data from the
+// given reader as the "file" part. The multipart.Writer is closed after the file is written.
+func (request *requestWriteFile) writeMultipart(writer *multipart.Writer, data io.Reader) error {
+	// Collect the form fields of the request
+	fields := [][2]string{
+		{"fileName", request.FileName},
+		{"keepPrevious", strconv.FormatBool(request.KeepPrevious)},
+		{"createFolders", strconv.FormatBool(request.CreateFolders)},
+		{"isProvenance", strconv.FormatBool(request.IsProvenance)},
+	}
+
+	if request.Replication != 0 {
+		fields = append(fields, [2]string{"replication", strconv.Itoa(request.Replication)})
+	}
+
+	if request.Encryption != 0 {
+		fields = append(fields, [2]string{"encryptionType", strconv.Itoa(int(request.Encryption))})
+	}
+
+	// Write the form fields
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("failed to write field '%v': %w", field[0], err)
+		}
+	}
+
+	// Write the file data
+	part, err := writer.CreateFormFile("file", path.Base(request.FileName))
+	if err != nil {
+		return fmt.Errorf("failed to create file part: %w", err)
+	}
+
+	if _, err := io.Copy(part, data); err != nil {
+		return fmt.Errorf("failed to write file part: %w", err)
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,136 @@
+package moibit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// testRetryPolicy is a RetryPolicy with short delays for tests
+var testRetryPolicy = moibit.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestWriteFileBinary(t *testing.T) {
+	server, client := newTestClient(t)
+
+	data := []byte{0x00, 0xff, 0xfe, 0x80, 0x01}
+	file, err := client.WriteFile(data, "/blob.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.FileSize != len(data) || server.Calls("/writefile") != 1 || server.Calls("/writetexttofile") != 0 {
+		t.Errorf("invalid UTF-8 written as %+v with %v multipart calls, want a multipart upload", file, server.Calls("/writefile"))
+	}
+
+	read, err := client.ReadFile("/blob.bin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(read, data) {
+		t.Errorf("ReadFile = %x, want %x", read, data)
+	}
+}
+
+func TestWriteFileBinaryRetried(t *testing.T) {
+	server, client := newTestClient(t, moibit.Retry(testRetryPolicy))
+	server.Inject(moibittest.Fault{Endpoint: "/writefile", Status: http.StatusServiceUnavailable, Times: 2})
+
+	data := []byte("text uploaded as binary")
+	if _, err := client.WriteFile(data, "/retried.txt", moibit.BinaryUpload(), moibit.RetryWrite()); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := server.Calls("/writefile"); calls != 3 {
+		t.Errorf("%v calls to /writefile, want 3", calls)
+	}
+
+	// The replayed attempts must carry the complete multipart form
+	read, err := client.ReadFile("/retried.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(read, data) {
+		t.Errorf("ReadFile = %q, want %q", read, data)
+	}
+}
+
+func TestWriteFileBinaryNotRetried(t *testing.T) {
+	server, client := newTestClient(t, moibit.Retry(testRetryPolicy))
+	server.Inject(moibittest.Fault{Endpoint: "/writefile", Status: http.StatusServiceUnavailable})
+
+	_, err := client.WriteFile([]byte("data"), "/once.txt", moibit.BinaryUpload())
+
+	var apiErr *moibit.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("WriteFile = %v, want the 503 APIError", err)
+	}
+
+	if calls := server.Calls("/writefile"); calls != 1 {
+		t.Errorf("%v calls to /writefile without RetryWrite, want 1", calls)
+	}
+}
+
+func TestWriteFrom(t *testing.T) {
+	server, client := newTestClient(t)
+
+	data := bytes.Repeat([]byte("streamed "), 10000)
+
+	var reported int64
+	file, err := client.WriteFrom(context.Background(), bytes.NewReader(data), "/streamed.txt", moibit.Progress(func(sent int64) {
+		reported = sent
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.FileSize != len(data) || reported != int64(len(data)) {
+		t.Errorf("WriteFrom wrote %v bytes and reported %v, want %v", file.FileSize, reported, len(data))
+	}
+
+	if calls := server.Calls("/writefile"); calls != 1 {
+		t.Errorf("%v calls to /writefile, want 1", calls)
+	}
+}
+
+func TestWriteFromNotRetried(t *testing.T) {
+	server, client := newTestClient(t, moibit.Retry(testRetryPolicy))
+	server.Inject(moibittest.Fault{Endpoint: "/writefile", Status: http.StatusServiceUnavailable})
+
+	// The stream cannot be replayed, so the write is not retried even if it opts in
+	_, err := client.WriteFrom(context.Background(), strings.NewReader("data"), "/stream.txt", moibit.RetryWrite())
+	if err == nil {
+		t.Fatal("WriteFrom succeeded after an injected 503")
+	}
+
+	if calls := server.Calls("/writefile"); calls != 1 {
+		t.Errorf("%v calls to /writefile, want 1", calls)
+	}
+
+	if status, err := client.FileStatus("/stream.txt"); err == nil && status.Exists() {
+		t.Error("the failed write created the file")
+	}
+}
+
+func TestWriteFromNotReplayed(t *testing.T) {
+	server, client := newTestClient(t)
+	server.ExpireAuth()
+
+	// The rejected stream cannot be replayed after the client authenticates again
+	_, err := client.WriteFrom(context.Background(), strings.NewReader("data"), "/stream.txt")
+	if !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("WriteFrom = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+
+	if calls := server.Calls("/writefile"); calls != 1 {
+		t.Errorf("%v calls to /writefile, want 1", calls)
+	}
+}
@@ -0,0 +1,105 @@
+package moibit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// expectedVersion returns the descriptor of the given version of the file at the given path,
+// which is the active version described by FileStatus if the version is 0.
+// Returns ErrNotFound if the file version does not exist.
+func (client *Client) expectedVersion(ctx context.Context, path string, version int) (FileVersionDescriptor, error) {
+	var expected FileVersionDescriptor
+
+	if version == 0 {
+		file, err := client.FileStatusContext(ctx, path)
+		if err != nil {
+			return expected, err
+		}
+
+		if !file.Exists() || file.IsDirectory {
+			return expected, fmt.Errorf("file '%v': %w", path, ErrNotFound)
+		}
+
+		expected = file.FileVersionDescriptor
+	} else {
+		versions, err := client.FileVersionsContext(ctx, path)
+		if err != nil {
+			return expected, err
+		}
+
+		found := false
+		for _, descriptor := range versions {
+			if descriptor.Version == version {
+				expected, found = descriptor, true
+				break
+			}
+		}
+
+		if !found {
+			return expected, fmt.Errorf("version %v of file '%v': %w", version, path, ErrNotFound)
+		}
+	}
+
+	// Only hashes that can be computed locally can be verified
+	if _, ok := CIDVersionOf(expected.Hash); !ok {
+		return expected, fmt.Errorf("hash '%v' of file '%v' cannot be verified", expected.Hash, path)
+	}
+
+	return expected, nil
+}
+
+// verifyingReader is an io.ReadCloser that hashes the data read from the underlying reader and
+// compares it against the expected hash and size of the file version once the data is exhausted.
+type verifyingReader struct {
+	io.ReadCloser
+
+	path     string
+	expected FileVersionDescriptor
+	hasher   *Hasher
+	err      error
+}
+
+// newVerifyingReader returns a verifyingReader for the data of the file at the given path with the given expected version
+func newVerifyingReader(r io.ReadCloser, path string, expected FileVersionDescriptor) *verifyingReader {
+	version, _ := CIDVersionOf(expected.Hash)
+	return &verifyingReader{ReadCloser: r, path: path, expected: expected, hasher: NewHasher(version)}
+}
+
+// Read implements the io.Reader interface for verifyingReader.
+// Returns an IntegrityError instead of io.EOF if the data does not match the expected hash and size.
+// A body that ends before its Content-Length (io.ErrUnexpectedEOF) is also verified, so that a
+// truncated response is reported as an IntegrityError.
+func (reader *verifyingReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+
+	n, err := reader.ReadCloser.Read(p)
+	_, _ = reader.hasher.Write(p[:n])
+
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		if verr := reader.verify(); verr != nil {
+			reader.err = verr
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+// verify compares the hash and size of the data read against the expected version
+func (reader *verifyingReader) verify() error {
+	size, hash := reader.hasher.Size(), reader.hasher.Sum()
+	if size == int64(reader.expected.FileSize) && hash == reader.expected.Hash {
+		return nil
+	}
+
+	return &IntegrityError{
+		Path: reader.path, Version: reader.expected.Version,
+		ExpectedHash: reader.expected.Hash, ExpectedSize: int64(reader.expected.FileSize),
+		ActualHash: hash, ActualSize: size,
+	}
+}
@@ -0,0 +1,91 @@
+package moibit_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+)
+
+// tamper returns a Middleware that flips the first byte of the data of every file read from MOIBit
+func tamper() moibit.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return moibit.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			response, err := next.RoundTrip(request)
+			if err != nil || !strings.HasSuffix(request.URL.Path, "/readfile") || response.StatusCode != 200 {
+				return response, err
+			}
+
+			data, err := io.ReadAll(response.Body)
+			response.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			if len(data) > 0 {
+				data[0] ^= 0xff
+			}
+
+			response.Body = io.NopCloser(bytes.NewReader(data))
+			return response, nil
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, map[string]string{"/a.txt": "first"})
+
+	if _, err := client.WriteFile([]byte("second"), "/a.txt", moibit.KeepPrevious()); err != nil {
+		t.Fatal(err)
+	}
+
+	for version, want := range map[int]string{0: "second", 1: "first", 2: "second"} {
+		data, err := client.ReadFile("/a.txt", version, moibit.Verify())
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(version %v) = %q, %v, want %q", version, data, err, want)
+		}
+	}
+
+	if _, err := client.ReadFile("/a.txt", 5, moibit.Verify()); !errors.Is(err, moibit.ErrNotFound) {
+		t.Errorf("ReadFile of a missing version = %v, want %v", err, moibit.ErrNotFound)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	_, client := newTestClient(t, moibit.WrapTransport(tamper()))
+	writeFiles(t, client, map[string]string{"/a.txt": "the original data"})
+
+	// The tampered data goes unnoticed without verification
+	if data, err := client.ReadFile("/a.txt", 0); err != nil || string(data) == "the original data" {
+		t.Fatalf("ReadFile without Verify = %q, %v, want the tampered data", data, err)
+	}
+
+	_, err := client.ReadFile("/a.txt", 0, moibit.Verify())
+	if !errors.Is(err, moibit.ErrIntegrity) {
+		t.Fatalf("ReadFile of a tampered file = %v, want %v", err, moibit.ErrIntegrity)
+	}
+
+	var integrity *moibit.IntegrityError
+	if !errors.As(err, &integrity) {
+		t.Fatalf("ReadFile of a tampered file = %T, want *IntegrityError", err)
+	}
+
+	want := moibit.HashBytes([]byte("the original data"), moibit.CIDv0)
+	if integrity.Path != "/a.txt" || integrity.ExpectedHash != want || integrity.ActualHash == want {
+		t.Errorf("IntegrityError = %+v, want a hash mismatch for /a.txt", integrity)
+	}
+
+	if integrity.ExpectedSize != 17 || integrity.ActualSize != 17 {
+		t.Errorf("IntegrityError sizes = %v, %v, want 17, 17", integrity.ExpectedSize, integrity.ActualSize)
+	}
+
+	// The mismatch is reported at the end of a stream
+	if _, err := client.ReadFileTo(io.Discard, "/a.txt", 0, moibit.Verify()); !errors.Is(err, moibit.ErrIntegrity) {
+		t.Errorf("ReadFileTo of a tampered file = %v, want %v", err, moibit.ErrIntegrity)
+	}
+}
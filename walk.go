@@ -0,0 +1,221 @@
+package moibit
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// WalkFunc is the type of the function called by Walk for each file or directory visited.
+//
+// The path argument is the absolute path of the file or directory and file is its FileDescriptor.
+// The err argument reports an error related to the path, in which case the function decides how to proceed:
+// if the root cannot be found, it is called with the error and an empty FileDescriptor; if a directory
+// cannot be listed, it is called a second time for the directory with the error.
+//
+// Like fs.WalkDirFunc, returning fs.SkipDir for a directory skips its contents, returning fs.SkipDir
+// for a file skips the remaining files in its directory, returning fs.SkipAll stops the walk and
+// returning any other error stops the walk with that error.
+type WalkFunc func(path string, file FileDescriptor, err error) error
+
+// WalkOption is an option for the Walk method of Client.
+type WalkOption func(*walkConfig) error
+
+// walkConfig is the configuration of a walk
+type walkConfig struct {
+	concurrency int
+	files       bool
+	directories bool
+}
+
+// WalkConcurrency returns a WalkOption that can be used to set the number of directories that are listed
+// concurrently during the walk. Sibling directories are listed ahead of being visited, while the WalkFunc
+// is still called sequentially in depth-first order. By default, directories are listed one at a time.
+func WalkConcurrency(n int) WalkOption {
+	return func(config *walkConfig) error {
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency: %v", n)
+		}
+
+		config.concurrency = n
+		return nil
+	}
+}
+
+// OnlyFiles returns a WalkOption that can be used to call the WalkFunc only for files.
+// Directories are still traversed, but the WalkFunc is only called for them if they cannot be listed.
+func OnlyFiles() WalkOption {
+	return func(config *walkConfig) error {
+		config.files, config.directories = true, false
+		return nil
+	}
+}
+
+// OnlyDirectories returns a WalkOption that can be used to call the WalkFunc only for directories.
+func OnlyDirectories() WalkOption {
+	return func(config *walkConfig) error {
+		config.files, config.directories = false, true
+		return nil
+	}
+}
+
+// Walk walks the tree of files rooted at the given path on MOIBit, calling fn for each file
+// or directory in the tree, including the root. The tree is traversed depth-first with the
+// entries of each directory visited in lexical order. Directories are listed with ListFiles.
+// It accepts a variadic number of WalkOption to list directories concurrently and to filter the
+// visited entries. The WalkFunc is never called concurrently.
+func (client *Client) Walk(ctx context.Context, root string, fn WalkFunc, opts ...WalkOption) error {
+	config := &walkConfig{concurrency: 1, files: true, directories: true}
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return fmt.Errorf("walk option could not be applied: %w", err)
+		}
+	}
+
+	// Cancel any pending listings once the walk is complete
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	walker := &walker{
+		client: client, ctx: ctx, fn: fn, config: config,
+		slots: make(chan struct{}, config.concurrency),
+	}
+
+	err := walker.walkRoot(path.Join("/", root))
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// walker is the state of a Walk
+type walker struct {
+	client *Client
+	ctx    context.Context
+	fn     WalkFunc
+	config *walkConfig
+	slots  chan struct{}
+}
+
+// listing is the pending result of listing a directory
+type listing struct {
+	done  chan struct{}
+	files []FileDescriptor
+	err   error
+}
+
+// list starts listing the directory at the given path in the background.
+// The number of concurrent listings is bounded by the slots of the walker.
+func (walker *walker) list(dirpath string) *listing {
+	result := &listing{done: make(chan struct{})}
+
+	go func() {
+		defer close(result.done)
+
+		select {
+		case walker.slots <- struct{}{}:
+			defer func() { <-walker.slots }()
+		case <-walker.ctx.Done():
+			result.err = walker.ctx.Err()
+			return
+		}
+
+		result.files, result.err = walker.client.ListFilesContext(walker.ctx, dirpath)
+	}()
+
+	return result
+}
+
+// walkRoot visits the root of the walk
+func (walker *walker) walkRoot(root string) error {
+	// The root directory always exists
+	if root == "/" {
+		return walker.walkDirectory(root, FileDescriptor{IsDirectory: true}, walker.list(root))
+	}
+
+	file, err := walker.client.FileStatusContext(walker.ctx, root)
+	if err == nil && !file.Exists() {
+		err = &fs.PathError{Op: "walk", Path: root, Err: fs.ErrNotExist}
+	}
+
+	if err != nil {
+		return walker.fn(root, FileDescriptor{}, err)
+	}
+
+	if !file.IsDirectory {
+		return walker.fn(root, file, nil)
+	}
+
+	return walker.walkDirectory(root, file, walker.list(root))
+}
+
+// walkDirectory visits the directory at the given path and its contents with the pending listing of it
+func (walker *walker) walkDirectory(dirpath string, dir FileDescriptor, pending *listing) error {
+	if walker.config.directories {
+		if err := walker.fn(dirpath, dir, nil); err != nil {
+			if err == fs.SkipDir {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	// Wait for the listing of the directory
+	<-pending.done
+	if pending.err != nil {
+		if err := walker.fn(dirpath, dir, pending.err); err != nil && err != fs.SkipDir {
+			return err
+		}
+
+		return nil
+	}
+
+	files := pending.files
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	// Start listing the subdirectories ahead of visiting them if they are listed concurrently
+	listings := make(map[string]*listing)
+	if walker.config.concurrency > 1 {
+		for _, file := range files {
+			if file.IsDirectory {
+				childpath := path.Join(dirpath, file.Name())
+				listings[childpath] = walker.list(childpath)
+			}
+		}
+	}
+
+	for _, file := range files {
+		childpath := path.Join(dirpath, file.Name())
+
+		if file.IsDirectory {
+			child, ok := listings[childpath]
+			if !ok {
+				child = walker.list(childpath)
+			}
+
+			if err := walker.walkDirectory(childpath, file, child); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !walker.config.files {
+			continue
+		}
+
+		if err := walker.fn(childpath, file, nil); err != nil {
+			if err == fs.SkipDir {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
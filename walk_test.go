@@ -0,0 +1,184 @@
+package moibit_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"testing"
+
+	moibit "github.com/manishmeganathan/go-moibit-client"
+	"github.com/manishmeganathan/go-moibit-client/moibittest"
+)
+
+// walkTree is the tree of files walked by the tests
+var walkTree = map[string]string{
+	"/b/z.txt":     "z",
+	"/b/a.txt":     "a",
+	"/b/c/d.txt":   "d",
+	"/a.txt":       "a",
+	"/e/f/g/h.txt": "h",
+	"/c.txt":       "c",
+}
+
+// walkPaths walks the tree from the given root and returns the visited paths
+func walkPaths(t *testing.T, client *moibit.Client, root string, fn moibit.WalkFunc, opts ...moibit.WalkOption) []string {
+	t.Helper()
+
+	visited := make([]string, 0)
+	err := client.Walk(context.Background(), root, func(path string, file moibit.FileDescriptor, err error) error {
+		if err != nil {
+			t.Fatalf("walk error for %v: %v", path, err)
+		}
+
+		if path != file.FullPath() {
+			t.Errorf("walk path %v with the descriptor of %v", path, file.FullPath())
+		}
+
+		visited = append(visited, path)
+		if fn != nil {
+			return fn(path, file, err)
+		}
+
+		return nil
+	}, opts...)
+
+	if err != nil {
+		t.Fatalf("Walk(%v) failed: %v", root, err)
+	}
+
+	return visited
+}
+
+func TestWalkOrder(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, walkTree)
+
+	want := []string{
+		"/", "/a.txt",
+		"/b", "/b/a.txt", "/b/c", "/b/c/d.txt", "/b/z.txt",
+		"/c.txt",
+		"/e", "/e/f", "/e/f/g", "/e/f/g/h.txt",
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		if got := walkPaths(t, client, "/", nil, moibit.WalkConcurrency(concurrency)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Walk with concurrency %v = %v, want %v", concurrency, got, want)
+		}
+	}
+
+	if got := walkPaths(t, client, "/b", nil); !reflect.DeepEqual(got, want[2:7]) {
+		t.Errorf("Walk(/b) = %v, want %v", got, want[2:7])
+	}
+
+	if got := walkPaths(t, client, "/b/z.txt", nil); !reflect.DeepEqual(got, []string{"/b/z.txt"}) {
+		t.Errorf("Walk of a file = %v, want only the file", got)
+	}
+}
+
+func TestWalkFilter(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, walkTree)
+
+	files := []string{"/a.txt", "/b/a.txt", "/b/c/d.txt", "/b/z.txt", "/c.txt", "/e/f/g/h.txt"}
+	if got := walkPaths(t, client, "/", nil, moibit.OnlyFiles()); !reflect.DeepEqual(got, files) {
+		t.Errorf("Walk with OnlyFiles = %v, want %v", got, files)
+	}
+
+	directories := []string{"/", "/b", "/b/c", "/e", "/e/f", "/e/f/g"}
+	if got := walkPaths(t, client, "/", nil, moibit.OnlyDirectories()); !reflect.DeepEqual(got, directories) {
+		t.Errorf("Walk with OnlyDirectories = %v, want %v", got, directories)
+	}
+
+	if err := client.Walk(context.Background(), "/", nil, moibit.WalkConcurrency(0)); err == nil {
+		t.Error("Walk with concurrency 0 succeeded")
+	}
+}
+
+func TestWalkSkip(t *testing.T) {
+	_, client := newTestClient(t)
+	writeFiles(t, client, walkTree)
+
+	tests := []struct {
+		name string
+		skip string
+		err  error
+		want []string
+	}{
+		{"skip directory", "/b", fs.SkipDir, []string{"/", "/a.txt", "/b", "/c.txt", "/e", "/e/f", "/e/f/g", "/e/f/g/h.txt"}},
+		{"skip nested directory", "/e/f", fs.SkipDir, []string{"/", "/a.txt", "/b", "/b/a.txt", "/b/c", "/b/c/d.txt", "/b/z.txt", "/c.txt", "/e", "/e/f"}},
+		{"skip siblings of file", "/b/a.txt", fs.SkipDir, []string{"/", "/a.txt", "/b", "/b/a.txt", "/c.txt", "/e", "/e/f", "/e/f/g", "/e/f/g/h.txt"}},
+		{"skip root", "/", fs.SkipDir, []string{"/"}},
+		{"skip all", "/b/c", fs.SkipAll, []string{"/", "/a.txt", "/b", "/b/a.txt", "/b/c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := walkPaths(t, client, "/", func(path string, _ moibit.FileDescriptor, _ error) error {
+				if path == test.skip {
+					return test.err
+				}
+
+				return nil
+			})
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Walk = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	server, client := newTestClient(t)
+	writeFiles(t, client, walkTree)
+
+	// A missing root is reported to the WalkFunc
+	var reported error
+	err := client.Walk(context.Background(), "/missing", func(path string, file moibit.FileDescriptor, err error) error {
+		reported = err
+		return nil
+	})
+
+	if err != nil || !errors.Is(reported, fs.ErrNotExist) {
+		t.Errorf("Walk of a missing root = %v with %v reported, want %v reported", err, reported, fs.ErrNotExist)
+	}
+
+	// A directory that cannot be listed is visited a second time with the error
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", Status: http.StatusForbidden})
+
+	visits := make([]error, 0)
+	err = client.Walk(context.Background(), "/b/c", func(path string, file moibit.FileDescriptor, err error) error {
+		visits = append(visits, err)
+		return nil
+	})
+
+	if err != nil || len(visits) != 2 || visits[0] != nil || !errors.Is(visits[1], moibit.ErrUnauthorized) {
+		t.Errorf("Walk of an unlisted directory = %v with visits %v, want a second visit with the error", err, visits)
+	}
+
+	// An error returned by the WalkFunc stops the walk
+	stop := errors.New("stop")
+	server.Inject(moibittest.Fault{Endpoint: "/listfiles", Status: http.StatusForbidden})
+
+	err = client.Walk(context.Background(), "/b", func(path string, file moibit.FileDescriptor, err error) error {
+		return err
+	})
+
+	if !errors.Is(err, moibit.ErrUnauthorized) {
+		t.Errorf("Walk returning the listing error = %v, want %v", err, moibit.ErrUnauthorized)
+	}
+
+	err = client.Walk(context.Background(), "/", func(path string, file moibit.FileDescriptor, err error) error {
+		if path == "/b/c" {
+			return stop
+		}
+
+		return nil
+	})
+
+	if err != stop {
+		t.Errorf("Walk = %v, want the error of the WalkFunc", err)
+	}
+}